@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,6 +16,7 @@ import (
 	"tyr-blog-img/internal/config"
 	"tyr-blog-img/internal/database"
 	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/logging"
 	"tyr-blog-img/internal/pixiv"
 	"tyr-blog-img/internal/storage"
 	"tyr-blog-img/internal/telegram"
@@ -26,44 +27,72 @@ import (
 
 func main() {
 	cfg := config.Load()
+	logging.Setup(os.Stderr, cfg.LogFormat, cfg.LogLevel)
 
 	if !cfg.IsTelegramPollingMode() && !cfg.IsTelegramWebhookMode() {
-		log.Fatalf("unsupported BOT_MODE %q (use polling or webhook)", cfg.BotMode)
+		fatal("unsupported BOT_MODE (use polling or webhook)", "bot_mode", cfg.BotMode)
 	}
-	if !cfg.HasD1() {
-		log.Fatal("D1 credentials missing")
+	if !cfg.UseSQLite() && !cfg.HasD1() {
+		fatal("D1 credentials missing (or set DB_BACKEND=sqlite)")
 	}
-	if !cfg.HasR2() {
-		log.Fatal("R2 credentials missing")
+	if !cfg.UseFSStorage() && !cfg.HasR2() {
+		fatal("R2 credentials missing (or set STORAGE_BACKEND=fs)")
 	}
 
-	db := database.New(cfg.D1AccountID, cfg.D1APIToken, cfg.D1DatabaseID)
+	var db database.Store
+	if cfg.UseSQLite() {
+		sqliteDB, err := database.NewSQLite(cfg.SQLitePath)
+		if err != nil {
+			fatal("open sqlite failed", "err", err)
+		}
+		defer sqliteDB.Close()
+		db = sqliteDB
+	} else {
+		db = database.New(cfg.D1AccountID, cfg.D1APIToken, cfg.D1DatabaseID)
+	}
 	bootstrapCtx, cancelBootstrap := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancelBootstrap()
 	if err := db.EnsureSchema(bootstrapCtx); err != nil {
-		log.Fatalf("ensure schema error: %v", err)
+		fatal("ensure schema failed", "err", err)
+	}
+	if cfg.UseSQLite() {
+		slog.Info("SQLite schema ready", "path", cfg.SQLitePath)
+	} else {
+		slog.Info("D1 schema ready")
 	}
-	log.Println("D1 schema ready")
 	if applied, err := db.EnsureGallerySeqBaselineIfEmpty(bootstrapCtx, cfg.GalleryBaselineH, cfg.GalleryBaselineV); err != nil {
-		log.Fatalf("init gallery seq baseline error: %v", err)
+		fatal("init gallery seq baseline failed", "err", err)
 	} else if applied {
-		log.Printf("gallery seq baseline initialized: h=%d, v=%d", cfg.GalleryBaselineH, cfg.GalleryBaselineV)
+		slog.Info("gallery seq baseline initialized", "h", cfg.GalleryBaselineH, "v", cfg.GalleryBaselineV)
 	} else if cfg.GalleryBaselineH > 0 || cfg.GalleryBaselineV > 0 {
-		log.Printf("gallery seq baseline skipped (already initialized or gallery_images not empty): h=%d, v=%d", cfg.GalleryBaselineH, cfg.GalleryBaselineV)
+		slog.Info("gallery seq baseline skipped (already initialized or gallery_images not empty)", "h", cfg.GalleryBaselineH, "v", cfg.GalleryBaselineV)
 	}
 
-	r2, err := storage.NewR2Client(bootstrapCtx, storage.R2Config{
-		Endpoint:  cfg.R2Endpoint,
-		Region:    cfg.R2Region,
-		Bucket:    cfg.R2Bucket,
-		AccessKey: cfg.R2AccessKey,
-		SecretKey: cfg.R2SecretKey,
-	})
-	if err != nil {
-		log.Fatalf("init r2 client error: %v", err)
+	var objectStore gallery.ObjectStore
+	if cfg.UseFSStorage() {
+		fs, err := storage.NewFSClient(storage.FSConfig{Root: cfg.FSRoot})
+		if err != nil {
+			fatal("init fs storage failed", "err", err)
+		}
+		slog.Info("object store: local filesystem", "root", cfg.FSRoot)
+		objectStore = fs
+	} else {
+		r2, err := storage.NewR2Client(bootstrapCtx, storage.R2Config{
+			Endpoint:  cfg.R2Endpoint,
+			Region:    cfg.R2Region,
+			Bucket:    cfg.R2Bucket,
+			AccessKey: cfg.R2AccessKey,
+			SecretKey: cfg.R2SecretKey,
+		})
+		if err != nil {
+			fatal("init r2 client failed", "err", err)
+		}
+		objectStore = r2
 	}
 
-	gallerySvc := gallery.NewService(db, r2, nil)
+	gallerySvc := gallery.NewService(db, objectStore, nil)
+	gallerySvc.PHashMaxDistance = cfg.GalleryPHashMaxDistance
+	gallerySvc.VariantWidths = cfg.GalleryVariantWidths
 	pv := pixiv.New(cfg.PixivPHPSESSID, cfg.PixivUserID, cfg.PixivRest)
 
 	var tg *telegram.Client
@@ -71,16 +100,17 @@ func main() {
 		var botOpts []tgbot.Option
 		if cfg.IsTelegramWebhookMode() {
 			if cfg.TGWebhookSecret == "" {
-				log.Fatal("TELEGRAM_WEBHOOK_SECRET is required when BOT_MODE=webhook")
+				fatal("TELEGRAM_WEBHOOK_SECRET is required when BOT_MODE=webhook")
 			}
 			botOpts = append(botOpts, tgbot.WithWebhookSecretToken(cfg.TGWebhookSecret))
 		}
+		var err error
 		tg, err = telegram.New(cfg.BotToken, botOpts...)
 		if err != nil {
-			log.Fatalf("init telegram bot error: %v", err)
+			fatal("init telegram bot failed", "err", err)
 		}
 	} else {
-		log.Println("warning: BOT_TOKEN missing, telegram ingress disabled")
+		slog.Warn("BOT_TOKEN missing, telegram ingress disabled")
 	}
 
 	application := app.New(&cfg, db, tg, pv, gallerySvc)
@@ -91,7 +121,7 @@ func main() {
 		}, func(ctx context.Context, b *tgbot.Bot, update *models.Update) {
 			result, err := application.HandleTGMessage(ctx, update.Message)
 			if err != nil {
-				log.Printf("tg handle error: %v", err)
+				slog.ErrorContext(ctx, "tg handle failed", "ingest_id", fmt.Sprintf("tg:%d_%d", update.Message.Chat.ID, update.Message.ID), "err", err)
 				if update.Message != nil {
 					_, _ = b.SendMessage(ctx, &tgbot.SendMessageParams{
 						ChatID: update.Message.Chat.ID,
@@ -113,7 +143,10 @@ func main() {
 	defer stop()
 
 	application.StartPixivCrawler(ctx)
+	application.StartYandeCrawler(ctx)
 	application.StartTwitterAuthorCrawler(ctx)
+	application.StartDanbooruCrawler(ctx)
+	application.StartIngestWorkers(ctx)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -122,12 +155,15 @@ func main() {
 			return
 		}
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		_, _ = w.Write([]byte("tyr-blog-img is running\nhealth: /healthz\n"))
+		_, _ = w.Write([]byte("tyr-blog-img is running\nhealth: /healthz\ncounts: /counts.json\nrandom: /random?o=h|v, /random.js\nmetrics: /metrics\n"))
 	})
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		_, _ = w.Write([]byte("ok"))
 	})
+	application.RegisterPublicHandlers(mux)
+	application.RegisterAPIHandlers(mux)
+	application.RegisterMetricsHandler(mux)
 	if tg != nil && cfg.IsTelegramWebhookMode() {
 		webhookHandler := tg.Bot.WebhookHandler()
 		mux.HandleFunc("/telegram/webhook", func(w http.ResponseWriter, r *http.Request) {
@@ -142,9 +178,9 @@ func main() {
 
 	httpSrv := &http.Server{Addr: cfg.ListenAddr, Handler: mux}
 	go func() {
-		log.Printf("HTTP server listening on %s", cfg.ListenAddr)
+		slog.Info("HTTP server listening", "addr", cfg.ListenAddr)
 		if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("http server error: %v", err)
+			fatal("http server failed", "err", err)
 		}
 	}()
 
@@ -159,12 +195,12 @@ func main() {
 					AllowedUpdates: []string{models.AllowedUpdateMessage},
 				}); err != nil {
 					cancelWebhook()
-					log.Fatalf("set telegram webhook error: %v", err)
+					fatal("set telegram webhook failed", "err", err)
 				}
 				cancelWebhook()
-				log.Printf("telegram webhook configured: %s", cfg.TGWebhookURL)
+				slog.Info("telegram webhook configured", "url", cfg.TGWebhookURL)
 			} else {
-				log.Println("telegram webhook mode enabled; TELEGRAM_WEBHOOK_URL not set, configure setWebhook manually")
+				slog.Info("telegram webhook mode enabled; TELEGRAM_WEBHOOK_URL not set, configure setWebhook manually")
 			}
 		} else {
 			if cfg.DeleteWebhookOnPolling {
@@ -172,9 +208,9 @@ func main() {
 				err := deleteTelegramWebhookBeforePolling(webhookCtx, tg, cfg.BotToken)
 				cancelWebhook()
 				if err != nil {
-					log.Printf("warning: delete telegram webhook before polling failed: %v; polling may still hit getUpdates conflict", err)
+					slog.Warn("delete telegram webhook before polling failed; polling may still hit getUpdates conflict", "err", err)
 				} else {
-					log.Println("telegram webhook deleted before polling")
+					slog.Info("telegram webhook deleted before polling")
 				}
 			}
 			go tg.Start(ctx)
@@ -189,7 +225,13 @@ func main() {
 	if tg != nil {
 		tg.Stop()
 	}
-	log.Println("shutdown complete")
+	slog.Info("shutdown complete")
+}
+
+// fatal logs at error level and exits, the slog counterpart of log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
 func deleteTelegramWebhookBeforePolling(ctx context.Context, tg *telegram.Client, token string) error {
@@ -198,7 +240,7 @@ func deleteTelegramWebhookBeforePolling(ctx context.Context, tg *telegram.Client
 	}); err == nil {
 		return nil
 	} else {
-		log.Printf("telegram deleteWebhook via bot client failed, retrying direct HTTP: %v", err)
+		slog.Warn("telegram deleteWebhook via bot client failed, retrying direct HTTP", "err", err)
 		if fallbackErr := deleteTelegramWebhookDirect(ctx, token); fallbackErr != nil {
 			return fmt.Errorf("bot client: %v; direct HTTP: %w", err, fallbackErr)
 		}
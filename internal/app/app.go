@@ -3,25 +3,40 @@ package app
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"tyr-blog-img/internal/config"
 	"tyr-blog-img/internal/database"
 	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/logging"
 	"tyr-blog-img/internal/pixiv"
 	"tyr-blog-img/internal/telegram"
 
 	"github.com/go-telegram/bot/models"
 )
 
+// TGClient is the part of the Telegram client the ingest paths use.
+type TGClient interface {
+	DownloadFile(ctx context.Context, fileID string) ([]byte, string, error)
+	SendText(ctx context.Context, chatID int64, text string) error
+}
+
 type App struct {
 	Cfg     *config.Config
-	DB      *database.Client
-	TG      *telegram.Client
+	DB      database.Store
+	TG      TGClient
 	Pixiv   *pixiv.Client
 	Gallery *gallery.Service
+
+	albums      tgAlbumBuffer
+	autoPublish metadataAutoPublisher
+	publishMu   sync.Mutex
+	counts      countsCache
+	schedule    crawlerSchedule
 }
 
 type TGIngestResult struct {
@@ -29,10 +44,17 @@ type TGIngestResult struct {
 	Title     string
 	SourceURL string
 	Summary   string
+	// Stats are the image counts of a link ingest; nil for commands.
+	Stats *ingestStats
 }
 
-func New(cfg *config.Config, db *database.Client, tg *telegram.Client, pv *pixiv.Client, g *gallery.Service) *App {
-	return &App{Cfg: cfg, DB: db, TG: tg, Pixiv: pv, Gallery: g}
+func New(cfg *config.Config, db database.Store, tg *telegram.Client, pv *pixiv.Client, g *gallery.Service) *App {
+	a := &App{Cfg: cfg, DB: db, Pixiv: pv, Gallery: g}
+	// Keep TG a nil interface when there is no bot, so a.TG == nil holds.
+	if tg != nil {
+		a.TG = tg
+	}
+	return a
 }
 
 func (a *App) CanHandleTGMessage(msg *models.Message) bool {
@@ -62,6 +84,7 @@ func (a *App) HandleTGMessage(ctx context.Context, msg *models.Message) (*TGInge
 	if cmd, args := parseTGCommand(msg.Text); cmd != "" {
 		return a.handleTGCommand(ctx, cmd, args)
 	}
+	ctx = logging.WithIngest(ctx, "tg", fmt.Sprintf("%d_%d", msg.Chat.ID, msg.ID))
 
 	links := extractSupportedLinks(msg.Text, msg.Caption)
 	media, hasMedia := extractIncomingMedia(msg)
@@ -69,32 +92,22 @@ func (a *App) HandleTGMessage(ctx context.Context, msg *models.Message) (*TGInge
 		return nil, nil
 	}
 
+	if hasMedia && msg.MediaGroupID != "" {
+		// Album: collect the sibling messages and reply once in flushTGAlbum.
+		a.bufferTGAlbum(msg)
+		return nil, nil
+	}
+
 	if hasMedia && media.isImage() {
-		data, filePath, err := a.TG.DownloadFile(ctx, media.FileID)
-		if err != nil {
-			return nil, err
-		}
-		sourceKey := fmt.Sprintf("tg_%d_%d", msg.Chat.ID, msg.ID)
-		if media.FileUniqueID != "" {
-			sourceKey = fmt.Sprintf("tgfile_%s", media.FileUniqueID)
-		}
-		sourceURL := fmt.Sprintf("tg://chat/%d/message/%d", msg.Chat.ID, msg.ID)
-		storeRes, err := a.Gallery.StoreToGallery(ctx, gallery.StoreInput{
-			Source:       "tg",
-			SourceKey:    sourceKey,
-			SourceURL:    sourceURL,
-			SourcePostID: fmt.Sprintf("%d_%d", msg.Chat.ID, msg.ID),
-			RawData:      data,
-			CollectedAt:  time.Now().Unix(),
-		})
+		stored, err := a.storeTGImage(ctx, msg, media, fmt.Sprintf("%d_%d", msg.Chat.ID, msg.ID))
 		if err != nil {
 			return nil, err
 		}
 		return &TGIngestResult{
-			ID:        sourceKey,
+			ID:        stored.SourceKey,
 			Title:     fallbackTitle(msg.Caption, msg.Text, "TG"),
-			SourceURL: sourceURL,
-			Summary:   buildStoreSummary("TG图片", storeRes, filePath),
+			SourceURL: stored.SourceURL,
+			Summary:   buildStoreSummary("TG图片", stored.Result, stored.FilePath),
 		}, nil
 	}
 
@@ -105,6 +118,68 @@ func (a *App) HandleTGMessage(ctx context.Context, msg *models.Message) (*TGInge
 	return a.handleTGLinks(ctx, links)
 }
 
+type tgStoredImage struct {
+	SourceKey string
+	SourceURL string
+	FilePath  string
+	Result    gallery.StoreResult
+}
+
+func (a *App) storeTGImage(ctx context.Context, msg *models.Message, media incomingMedia, sourcePostID string) (tgStoredImage, error) {
+	data, filePath, err := a.TG.DownloadFile(ctx, media.FileID)
+	if err != nil {
+		return tgStoredImage{}, err
+	}
+	out := tgStoredImage{
+		SourceKey: fmt.Sprintf("tg_%d_%d", msg.Chat.ID, msg.ID),
+		SourceURL: fmt.Sprintf("tg://chat/%d/message/%d", msg.Chat.ID, msg.ID),
+		FilePath:  filePath,
+	}
+	if media.FileUniqueID != "" {
+		out.SourceKey = fmt.Sprintf("tgfile_%s", media.FileUniqueID)
+	}
+	authorName, authorID := tgImageAuthor(msg)
+	out.Result, err = a.storeToGallery(ctx, gallery.StoreInput{
+		Source:       "tg",
+		SourceKey:    out.SourceKey,
+		SourceURL:    out.SourceURL,
+		SourcePostID: sourcePostID,
+		RawData:      data,
+		CollectedAt:  time.Now().Unix(),
+		Title:        truncateRunes(strings.Split(strings.TrimSpace(msg.Caption), "\n")[0], 120),
+		AuthorName:   authorName,
+		AuthorID:     authorID,
+	})
+	return out, err
+}
+
+// tgImageAuthor credits where a forwarded image came from, else whoever
+// sent it. Hidden forwarders only have a name.
+func tgImageAuthor(msg *models.Message) (name, id string) {
+	if o := msg.ForwardOrigin; o != nil {
+		switch {
+		case o.MessageOriginUser != nil:
+			return tgUserName(o.MessageOriginUser.SenderUser), strconv.FormatInt(o.MessageOriginUser.SenderUser.ID, 10)
+		case o.MessageOriginHiddenUser != nil:
+			return strings.TrimSpace(o.MessageOriginHiddenUser.SenderUserName), ""
+		case o.MessageOriginChat != nil:
+			c := o.MessageOriginChat.SenderChat
+			return fallbackTitle(c.Title, c.Username), strconv.FormatInt(c.ID, 10)
+		case o.MessageOriginChannel != nil:
+			c := o.MessageOriginChannel.Chat
+			return fallbackTitle(c.Title, c.Username), strconv.FormatInt(c.ID, 10)
+		}
+	}
+	if msg.From != nil {
+		return tgUserName(*msg.From), strconv.FormatInt(msg.From.ID, 10)
+	}
+	return "", ""
+}
+
+func tgUserName(u models.User) string {
+	return fallbackTitle(strings.TrimSpace(u.FirstName+" "+u.LastName), u.Username)
+}
+
 func fallbackTitle(values ...string) string {
 	for _, v := range values {
 		v = strings.TrimSpace(v)
@@ -134,11 +209,19 @@ func buildStoreSummary(prefix string, res gallery.StoreResult, extra string) str
 		prefix, res.Image.Orientation, res.Image.Seq, res.Counts.H, res.Counts.V)
 }
 
-func (a *App) processPixivID(ctx context.Context, id string) {
+// processPixivID ingests one crawled artwork and reports whether it was
+// handled: every page stored or skipped, or the work filtered out.
+func (a *App) processPixivID(ctx context.Context, run *crawlRun, id string) bool {
 	stats, err := a.ingestPixivArtwork(ctx, id, "")
+	run.addStats(stats, err)
 	if err != nil {
-		log.Printf("pixiv ingest failed id=%s err=%v", id, err)
-		return
+		slog.WarnContext(ctx, "pixiv ingest failed", "id", id, "err", err)
+		return false
+	}
+	if stats.SkipReason != "" {
+		slog.InfoContext(ctx, "pixiv ingest filtered", "id", id, "reason", stats.SkipReason)
+		return true
 	}
-	log.Printf("pixiv ingest done id=%s added=%d skipped=%d failed=%d", id, stats.Downloaded, stats.Skipped, stats.Failed)
+	slog.InfoContext(ctx, "pixiv ingest done", "id", id, "added", stats.Downloaded, "skipped", stats.Skipped, "failed", stats.Failed)
+	return stats.Failed == 0
 }
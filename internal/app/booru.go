@@ -0,0 +1,443 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/logging"
+)
+
+const (
+	booruAPITimeout      = 60 * time.Second
+	booruDownloadTimeout = 90 * time.Second
+	booruAPIRetries      = 2
+	booruDownloadRetries = 2
+	booruRetryBackoff    = 1500 * time.Millisecond
+)
+
+// booruFlavor is the API dialect a board speaks.
+type booruFlavor string
+
+const (
+	// booruMoebooru: /post.json?tags=..., used by yande.re and Konachan.
+	booruMoebooru booruFlavor = "moebooru"
+	// booruGelbooru: index.php?page=dapi&s=post&q=index&json=1, used by
+	// Gelbooru and Safebooru.
+	booruGelbooru booruFlavor = "gelbooru"
+	// booruDanbooru: /posts.json?tags=..., see danbooru.go for its links,
+	// pools and login.
+	booruDanbooru booruFlavor = "danbooru"
+)
+
+// booruSite describes one board. SourcePrefix is both the gallery source
+// and the source key prefix ({prefix}_{id}), so mirrors of the same board
+// share it and dedupe against each other.
+type booruSite struct {
+	Name         string
+	BaseURL      string
+	Flavor       booruFlavor
+	Referer      string
+	SourcePrefix string
+}
+
+var booruSites = map[string]booruSite{
+	"yande.re":      {Name: "Yande", BaseURL: "https://yande.re", Flavor: booruMoebooru, Referer: "https://yande.re/", SourcePrefix: "yande"},
+	"konachan.com":  {Name: "Konachan", BaseURL: "https://konachan.com", Flavor: booruMoebooru, Referer: "https://konachan.com/", SourcePrefix: "konachan"},
+	"konachan.net":  {Name: "Konachan", BaseURL: "https://konachan.net", Flavor: booruMoebooru, Referer: "https://konachan.net/", SourcePrefix: "konachan"},
+	"gelbooru.com":  {Name: "Gelbooru", BaseURL: "https://gelbooru.com", Flavor: booruGelbooru, Referer: "https://gelbooru.com/", SourcePrefix: "gelbooru"},
+	"safebooru.org": {Name: "Safebooru", BaseURL: "https://safebooru.org", Flavor: booruGelbooru, Referer: "https://safebooru.org/", SourcePrefix: "safebooru"},
+}
+
+func booruSiteForHost(host string) (booruSite, bool) {
+	site, ok := booruSites[strings.TrimPrefix(strings.ToLower(strings.TrimSpace(host)), "www.")]
+	return site, ok
+}
+
+// parseBooruPostID extracts the post id from a post page URL of site.
+func parseBooruPostID(site booruSite, segments []string, query neturl.Values) (string, bool) {
+	switch site.Flavor {
+	case booruMoebooru:
+		if len(segments) >= 3 && segments[0] == "post" && segments[1] == "show" && booruIDPattern.MatchString(segments[2]) {
+			return segments[2], true
+		}
+	case booruGelbooru:
+		if len(segments) == 1 && segments[0] == "index.php" && query.Get("page") == "post" && query.Get("s") == "view" {
+			if id := query.Get("id"); booruIDPattern.MatchString(id) {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (s booruSite) postURL(id int) string {
+	switch s.Flavor {
+	case booruGelbooru:
+		return fmt.Sprintf("%s/index.php?page=post&s=view&id=%d", s.BaseURL, id)
+	case booruDanbooru:
+		return fmt.Sprintf("%s/posts/%d", s.BaseURL, id)
+	}
+	return fmt.Sprintf("%s/post/show/%d", s.BaseURL, id)
+}
+
+func (s booruSite) searchURL(tags string, limit int) string {
+	query := neturl.Values{"tags": {tags}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	switch s.Flavor {
+	case booruGelbooru:
+		query.Set("page", "dapi")
+		query.Set("s", "post")
+		query.Set("q", "index")
+		query.Set("json", "1")
+		return s.BaseURL + "/index.php?" + query.Encode()
+	case booruDanbooru:
+		return s.BaseURL + "/posts.json?" + query.Encode()
+	}
+	return s.BaseURL + "/post.json?" + query.Encode()
+}
+
+// booruPost is the flavour-independent view of a post.
+type booruPost struct {
+	ID          int
+	ParentID    int
+	HasChildren bool
+	URLs        []string
+	Tags        []string
+	// Artists is credited as the author when the board tags them apart.
+	Artists []string
+	// Uploader stands in for the artist on boards that only name the poster.
+	Uploader string
+	Title    string
+	// NotImage marks videos and ugoira zips, which the gallery cannot store.
+	NotImage bool
+}
+
+type moebooruPost struct {
+	ID          int    `json:"id"`
+	ParentID    *int   `json:"parent_id"`
+	HasChildren bool   `json:"has_children"`
+	FileURL     string `json:"file_url"`
+	JPEGURL     string `json:"jpeg_url"`
+	PNGURL      string `json:"png_url"`
+	SampleURL   string `json:"sample_url"`
+	Tags        string `json:"tags"`
+	TagsArtist  string `json:"tags_artist"`
+	Author      string `json:"author"`
+}
+
+// gelbooruPost covers both Gelbooru (string has_children, full file_url)
+// and Safebooru (file_url may be missing; directory + image instead).
+type gelbooruPost struct {
+	ID          booruInt  `json:"id"`
+	ParentID    booruInt  `json:"parent_id"`
+	HasChildren booruBool `json:"has_children"`
+	FileURL     string    `json:"file_url"`
+	SampleURL   string    `json:"sample_url"`
+	Directory   string    `json:"directory"`
+	Image       string    `json:"image"`
+	Tags        string    `json:"tags"`
+	TagsArtist  string    `json:"tags_artist"`
+	Owner       string    `json:"owner"`
+	Title       string    `json:"title"`
+}
+
+func (p moebooruPost) toBooruPost() booruPost {
+	out := booruPost{ID: p.ID, HasChildren: p.HasChildren}
+	if p.ParentID != nil {
+		out.ParentID = *p.ParentID
+	}
+	out.URLs = normalizeBooruURLs(p.FileURL, p.JPEGURL, p.PNGURL, p.SampleURL)
+	out.Tags = strings.Fields(p.Tags)
+	out.Artists = strings.Fields(p.TagsArtist)
+	out.Uploader = p.Author
+	return out
+}
+
+func (p gelbooruPost) toBooruPost(site booruSite) booruPost {
+	fallback := ""
+	if p.Directory != "" && p.Image != "" {
+		fallback = fmt.Sprintf("%s/images/%s/%s", site.BaseURL, p.Directory, p.Image)
+	}
+	return booruPost{
+		ID:          int(p.ID),
+		ParentID:    int(p.ParentID),
+		HasChildren: bool(p.HasChildren),
+		URLs:        normalizeBooruURLs(p.FileURL, fallback, p.SampleURL),
+		Tags:        strings.Fields(p.Tags),
+		Artists:     strings.Fields(p.TagsArtist),
+		Uploader:    p.Owner,
+		Title:       strings.TrimSpace(p.Title),
+	}
+}
+
+// author prefers the artist tags; the uploader is only a fallback.
+func (p booruPost) author() string {
+	if len(p.Artists) > 0 {
+		return strings.Join(p.Artists, ", ")
+	}
+	return strings.TrimSpace(p.Uploader)
+}
+
+func normalizeBooruURLs(rawCandidates ...string) []string {
+	out := make([]string, 0, len(rawCandidates))
+	seen := make(map[string]struct{}, len(rawCandidates))
+	for _, raw := range rawCandidates {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if strings.HasPrefix(raw, "//") {
+			raw = "https:" + raw
+		}
+		if _, ok := seen[raw]; ok {
+			continue
+		}
+		seen[raw] = struct{}{}
+		out = append(out, raw)
+	}
+	return out
+}
+
+// booruInt accepts both JSON numbers and numeric strings.
+type booruInt int
+
+func (n *booruInt) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(strings.TrimSpace(string(b)), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*n = booruInt(v)
+	return nil
+}
+
+// booruBool accepts JSON booleans and "true"/"false" strings.
+type booruBool bool
+
+func (v *booruBool) UnmarshalJSON(b []byte) error {
+	s := strings.ToLower(strings.Trim(strings.TrimSpace(string(b)), `"`))
+	*v = booruBool(s == "true" || s == "1")
+	return nil
+}
+
+func (a *App) ingestBooruFromLink(ctx context.Context, item supportedLink) (*TGIngestResult, error) {
+	site, ok := booruSiteForHost(item.Host)
+	if !ok {
+		return nil, fmt.Errorf("unsupported booru host %q", item.Host)
+	}
+	posts, err := fetchBooruFamilyPosts(ctx, site, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("%s post not found", strings.ToLower(site.Name))
+	}
+	stats, err := a.ingestBooruPosts(ctx, site, posts)
+	if err != nil {
+		return nil, err
+	}
+	return &TGIngestResult{
+		ID:        stats.FirstID,
+		Title:     stats.Title,
+		SourceURL: item.URL,
+		Summary:   fmt.Sprintf("%s %s done: +%d, skipped %d, failed %d", site.Name, item.ID, stats.Downloaded, stats.Skipped, stats.Failed),
+		Stats:     stats,
+	}, nil
+}
+
+func (a *App) ingestBooruPosts(ctx context.Context, site booruSite, posts []booruPost) (*ingestStats, error) {
+	stats := &ingestStats{Title: site.Name}
+	for _, post := range posts {
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+		ctx := logging.WithIngest(ctx, site.SourcePrefix, strconv.Itoa(post.ID))
+		sourceKey := fmt.Sprintf("%s_%d", site.SourcePrefix, post.ID)
+		if post.NotImage {
+			stats.Skipped++
+			continue
+		}
+		if blocked, err := a.DB.IsBlocked(ctx, sourceKey); err == nil && blocked {
+			stats.Skipped++
+			continue
+		}
+		if exists, _ := a.DB.ExistsGallerySourceKey(ctx, sourceKey); exists {
+			stats.Skipped++
+			continue
+		}
+		if len(post.URLs) == 0 {
+			// Restricted or deleted posts come without file URLs; there is
+			// nothing to download or retry.
+			slog.InfoContext(ctx, "booru post has no file url, skipped")
+			stats.Skipped++
+			continue
+		}
+		in := gallery.StoreInput{
+			Source:       site.SourcePrefix,
+			SourceKey:    sourceKey,
+			SourceURL:    site.postURL(post.ID),
+			SourcePostID: strconv.Itoa(post.ID),
+			Title:        post.Title,
+			AuthorName:   post.author(),
+			Tags:         post.Tags,
+		}
+		retry := ingestRetry{Kind: ingestJobKindHTTP, URLs: post.URLs, Referer: site.Referer}
+		var (
+			data []byte
+			err  error
+		)
+		for _, u := range post.URLs {
+			data, err = downloadWithHeadersRetry(ctx, u, site.Referer, booruDownloadTimeout, booruDownloadRetries, booruRetryBackoff)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "booru download failed", "err", err)
+			stats.Failed++
+			a.enqueueIngestRetry(ctx, in, retry, err)
+			continue
+		}
+		in.RawData = data
+		in.CollectedAt = time.Now().Unix()
+		storeRes, err := a.storeToGallery(ctx, in)
+		if err != nil {
+			stats.Failed++
+			a.enqueueIngestRetry(ctx, in, retry, err)
+			continue
+		}
+		if storeRes.Added {
+			stats.Downloaded++
+			if stats.FirstID == "" {
+				stats.FirstID = sourceKey
+			}
+		} else {
+			stats.Skipped++
+		}
+		time.Sleep(1200 * time.Millisecond)
+	}
+	return stats, nil
+}
+
+func fetchBooruPosts(ctx context.Context, site booruSite, tags string, limit int) ([]booruPost, error) {
+	tags = strings.TrimSpace(tags)
+	if tags == "" {
+		return nil, fmt.Errorf("%s tags is empty", strings.ToLower(site.Name))
+	}
+	body, err := downloadWithHeadersRetry(ctx, site.searchURL(tags, limit), site.Referer, booruAPITimeout, booruAPIRetries, booruRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBooruPosts(site, body)
+}
+
+func decodeBooruPosts(site booruSite, body []byte) ([]booruPost, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		// Safebooru answers an empty search with an empty body.
+		return nil, nil
+	}
+	switch site.Flavor {
+	case booruDanbooru:
+		var arr []danbooruPost
+		if err := json.Unmarshal(body, &arr); err != nil {
+			return nil, err
+		}
+		out := make([]booruPost, 0, len(arr))
+		for _, p := range arr {
+			out = append(out, p.toBooruPost())
+		}
+		return out, nil
+	case booruMoebooru:
+		var arr []moebooruPost
+		if err := json.Unmarshal(body, &arr); err != nil {
+			return nil, err
+		}
+		out := make([]booruPost, 0, len(arr))
+		for _, p := range arr {
+			out = append(out, p.toBooruPost())
+		}
+		return out, nil
+	}
+
+	var arr []gelbooruPost
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &arr); err != nil {
+			return nil, err
+		}
+	} else {
+		// Gelbooru wraps results: {"@attributes": {...}, "post": [...]}.
+		var wrapped struct {
+			Post []gelbooruPost `json:"post"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, err
+		}
+		arr = wrapped.Post
+	}
+	out := make([]booruPost, 0, len(arr))
+	for _, p := range arr {
+		out = append(out, p.toBooruPost(site))
+	}
+	return out, nil
+}
+
+func fetchBooruPost(ctx context.Context, site booruSite, id string) (*booruPost, error) {
+	arr, err := fetchBooruPosts(ctx, site, fmt.Sprintf("id:%s", strings.TrimSpace(id)), 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr) == 0 {
+		return nil, fmt.Errorf("%s post not found", strings.ToLower(site.Name))
+	}
+	return &arr[0], nil
+}
+
+// fetchBooruFamilyPosts returns the post, its parent and all siblings,
+// ordered by id.
+func fetchBooruFamilyPosts(ctx context.Context, site booruSite, id string) ([]booruPost, error) {
+	seed, err := fetchBooruPost(ctx, site, id)
+	if err != nil {
+		return nil, err
+	}
+	rootID := seed.ID
+	if seed.ParentID > 0 {
+		rootID = seed.ParentID
+	}
+	family, err := fetchBooruPosts(ctx, site, fmt.Sprintf("parent:%d", rootID), 0)
+	if err != nil || len(family) == 0 {
+		return []booruPost{*seed}, nil
+	}
+	merged := make(map[int]booruPost, len(family)+2)
+	for _, p := range family {
+		merged[p.ID] = p
+	}
+	merged[seed.ID] = *seed
+	if _, ok := merged[rootID]; !ok {
+		// Gelbooru's parent:N lists only the children.
+		if root, err := fetchBooruPost(ctx, site, strconv.Itoa(rootID)); err == nil {
+			merged[root.ID] = *root
+		}
+	}
+	out := make([]booruPost, 0, len(merged))
+	for _, p := range merged {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	yandeTagStatePrefix = "yande_tag_last_"
+	// booruCrawlPageSize is how many posts one poll asks for; only those
+	// newer than the saved id are kept, and at most the per-run cap of them
+	// is ingested.
+	booruCrawlPageSize = 100
+)
+
+// StartYandeCrawler polls the configured yande.re tag queries.
+func (a *App) StartYandeCrawler(ctx context.Context) {
+	if a.Cfg == nil || !a.Cfg.HasYandeCrawler() {
+		slog.InfoContext(ctx, "yande crawler disabled")
+		return
+	}
+	site, _ := booruSiteForHost("yande.re")
+	fetch := func(ctx context.Context, query string) ([]booruPost, error) {
+		return fetchBooruPosts(ctx, site, query, booruCrawlPageSize)
+	}
+	crawl := func(ctx context.Context) {
+		a.runCrawler(ctx, "yande", func(run *crawlRun) {
+			a.crawlBooruTagsOnce(ctx, run, site, yandeTagStatePrefix, a.Cfg.YandeTags, maxInt(a.Cfg.YandeFetchLimit, 20), fetch)
+		})
+	}
+	interval := time.Duration(maxInt(a.Cfg.YandeIntervalMin, 60)) * time.Minute
+	a.crawlEvery(ctx, "yande", interval, crawl, "yande")
+}
+
+// booruQueryFetch returns the newest posts of one tag query.
+type booruQueryFetch func(ctx context.Context, query string) ([]booruPost, error)
+
+// crawlBooruTagsOnce runs one pass over the tag queries of a board; yande
+// and Danbooru share it and differ only in how a query is fetched.
+func (a *App) crawlBooruTagsOnce(ctx context.Context, run *crawlRun, site booruSite, statePrefix string, queries []string, limit int, fetch booruQueryFetch) {
+	slog.InfoContext(ctx, "booru crawl started", "site", site.Name, "queries", len(queries))
+	for _, query := range queries {
+		if ctx.Err() != nil {
+			return
+		}
+		err := a.crawlBooruQuery(ctx, run, site, statePrefix, query, limit, fetch)
+		run.record(err)
+		if err != nil {
+			slog.WarnContext(ctx, "booru crawl failed", "site", site.Name, "tags", query, "err", err)
+		}
+		time.Sleep(1500 * time.Millisecond)
+	}
+	slog.InfoContext(ctx, "booru crawl finished", "site", site.Name)
+}
+
+// crawlBooruQuery ingests the fetched posts of one query whose id is above
+// the saved high-water mark, oldest first and at most limit of them (0: all).
+// The mark only moves past posts that were ingested or skipped; the first
+// failure ends the pass so the next run starts again at the failed post.
+// Only what one fetch returns is seen: posts that fall off the newest page
+// between runs, or past the cap while newer ones keep arriving, are missed.
+func (a *App) crawlBooruQuery(ctx context.Context, run *crawlRun, site booruSite, statePrefix, query string, limit int, fetch booruQueryFetch) error {
+	stateKey := statePrefix + strings.ToLower(strings.Join(strings.Fields(query), " "))
+	lastValue, _, err := a.DB.GetCrawlerState(ctx, stateKey)
+	if err != nil {
+		return fmt.Errorf("get crawler state: %w", err)
+	}
+	lastID, _ := strconv.Atoi(strings.TrimSpace(lastValue))
+
+	posts, err := fetch(ctx, query)
+	if err != nil {
+		return err
+	}
+	run.fetched()
+	fresh := posts[:0]
+	for _, p := range posts {
+		if p.ID > lastID {
+			fresh = append(fresh, p)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].ID < fresh[j].ID })
+	if limit > 0 && len(fresh) > limit {
+		fresh = fresh[:limit]
+	}
+
+	highestID := lastID
+	for _, p := range fresh {
+		if ctx.Err() != nil {
+			break
+		}
+		stats, err := a.ingestBooruPosts(ctx, site, []booruPost{p})
+		run.addStats(stats, err)
+		if err != nil {
+			break
+		}
+		if stats.Failed > 0 {
+			slog.WarnContext(ctx, "booru ingest failed", "site", site.Name, "tags", query, "post", p.ID)
+			break
+		}
+		highestID = p.ID
+	}
+	if highestID > lastID {
+		if err := a.DB.SetCrawlerState(ctx, stateKey, strconv.Itoa(highestID)); err != nil {
+			slog.WarnContext(ctx, "booru state update failed", "site", site.Name, "tags", query, "err", err)
+		}
+	}
+	return nil
+}
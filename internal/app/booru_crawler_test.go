@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCrawlBooruQueryStopsMarkAtFailedPost(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestAPIApp(t, 0)
+	a.Gallery.Processor = fakeUploadProcessor{}
+	a.Gallery.VariantWidths = nil
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/2.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("image " + r.URL.Path))
+	}))
+	defer srv.Close()
+	site := booruSite{Name: "Test", BaseURL: srv.URL, Flavor: booruMoebooru, SourcePrefix: "test"}
+	fetch := func(context.Context, string) ([]booruPost, error) {
+		// Newest first, as the boards return them.
+		return []booruPost{
+			{ID: 3, URLs: []string{srv.URL + "/3.jpg"}},
+			{ID: 2, URLs: []string{srv.URL + "/2.jpg"}},
+			{ID: 1, URLs: []string{srv.URL + "/1.jpg"}},
+		}, nil
+	}
+
+	run := &crawlRun{name: "test"}
+	if err := a.crawlBooruQuery(ctx, run, site, "test_tag_last_", "Kantoku", 0, fetch); err != nil {
+		t.Fatalf("crawlBooruQuery: %v", err)
+	}
+	if run.items.Downloaded != 1 || run.items.Failed != 1 {
+		t.Fatalf("items = +%d failed %d, want +1 failed 1", run.items.Downloaded, run.items.Failed)
+	}
+	if mark, _, err := a.DB.GetCrawlerState(ctx, "test_tag_last_kantoku"); err != nil || mark != "1" {
+		t.Fatalf("mark = %q, %v, want 1", mark, err)
+	}
+	// The pass stopped at the failure, so the newer post waits for the next
+	// run instead of moving the mark past post 2.
+	if exists, _ := a.DB.ExistsGallerySourceKey(ctx, "test_3"); exists {
+		t.Fatalf("post 3 was ingested past the failed post")
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import "testing"
+
+func TestExtractSupportedLinksBooru(t *testing.T) {
+	links := extractSupportedLinks(
+		"https://yande.re/post/show/1234",
+		"https://konachan.com/post/show/55/tag-list",
+		"https://gelbooru.com/index.php?page=post&s=view&id=777&tags=all",
+		"https://safebooru.org/index.php?page=post&s=view&id=888",
+		"https://gelbooru.com/index.php?page=post&s=list&tags=all",
+	)
+	want := []struct{ host, id string }{
+		{"yande.re", "1234"},
+		{"konachan.com", "55"},
+		{"gelbooru.com", "777"},
+		{"safebooru.org", "888"},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("links len = %d, want %d: %#v", len(links), len(want), links)
+	}
+	for i, w := range want {
+		if links[i].Type != linkBooru || links[i].Host != w.host || links[i].ID != w.id {
+			t.Fatalf("links[%d] = %#v, want %s %s", i, links[i], w.host, w.id)
+		}
+	}
+}
+
+func TestDecodeBooruPostsGelbooruFlavours(t *testing.T) {
+	gelbooru, _ := booruSiteForHost("gelbooru.com")
+	posts, err := decodeBooruPosts(gelbooru, []byte(`{"@attributes":{"count":1},"post":[{"id":10,"parent_id":9,"has_children":"false","file_url":"https://img3.gelbooru.com/images/a/b/c.jpg","owner":"uploader","title":"Sunset"}]}`))
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("gelbooru decode = %#v, %v", posts, err)
+	}
+	if p := posts[0]; p.ID != 10 || p.ParentID != 9 || p.HasChildren || len(p.URLs) != 1 {
+		t.Fatalf("gelbooru post = %#v", p)
+	}
+	if p := posts[0]; p.Title != "Sunset" || p.author() != "uploader" {
+		t.Fatalf("gelbooru credits = %q by %q", p.Title, p.author())
+	}
+
+	safebooru, _ := booruSiteForHost("safebooru.org")
+	posts, err = decodeBooruPosts(safebooru, []byte(`[{"id":"20","parent_id":0,"has_children":true,"directory":"1234","image":"abc.png"}]`))
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("safebooru decode = %#v, %v", posts, err)
+	}
+	if p := posts[0]; p.ID != 20 || !p.HasChildren || len(p.URLs) != 1 || p.URLs[0] != "https://safebooru.org/images/1234/abc.png" {
+		t.Fatalf("safebooru post = %#v", p)
+	}
+
+	if posts, err := decodeBooruPosts(safebooru, nil); err != nil || len(posts) != 0 {
+		t.Fatalf("empty safebooru body = %#v, %v", posts, err)
+	}
+}
+
+func TestDecodeBooruPostsMoebooruCredits(t *testing.T) {
+	yande, _ := booruSiteForHost("yande.re")
+	posts, err := decodeBooruPosts(yande, []byte(`[{"id":1,"file_url":"https://files.yande.re/a.png","tags":"kantoku sky","author":"poster"},{"id":2,"file_url":"https://files.yande.re/b.png","tags_artist":"kantoku","author":"poster"}]`))
+	if err != nil || len(posts) != 2 {
+		t.Fatalf("moebooru decode = %#v, %v", posts, err)
+	}
+	if got := posts[0].author(); got != "poster" {
+		t.Fatalf("untagged author = %q, want the uploader", got)
+	}
+	if got := posts[1].author(); got != "kantoku" {
+		t.Fatalf("tagged author = %q, want the artist tag", got)
+	}
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const crawlerPausedPrefix = "crawler_paused_"
+
+// crawlerLoopNames are the crawlEvery loops /pause and /resume accept.
+var crawlerLoopNames = []string{"pixiv", "twitter", "yande", "danbooru"}
+
+// crawlerPaused reports the /pause flag of loop stored in crawler_state. A
+// failed read counts as not paused, so a flaky D1 does not stop crawling.
+func (a *App) crawlerPaused(ctx context.Context, loop string) bool {
+	v, _, err := a.DB.GetCrawlerState(ctx, crawlerPausedPrefix+loop)
+	if err != nil {
+		slog.WarnContext(ctx, "crawler pause state read failed", "crawler", loop, "err", err)
+		return false
+	}
+	return v == "1"
+}
+
+// handleTGPause sets or clears the paused flag of a crawler loop. Pausing
+// also cancels the run in progress, so crawling stops within one request.
+func (a *App) handleTGPause(ctx context.Context, args string, paused bool) (*TGIngestResult, error) {
+	cmd, value := "resume", "0"
+	if paused {
+		cmd, value = "pause", "1"
+	}
+	loop := strings.ToLower(strings.TrimSpace(args))
+	if !slices.Contains(crawlerLoopNames, loop) {
+		return &TGIngestResult{Summary: fmt.Sprintf("Usage: /%s %s", cmd, strings.Join(crawlerLoopNames, "|"))}, nil
+	}
+	if err := a.DB.SetCrawlerState(ctx, crawlerPausedPrefix+loop, value); err != nil {
+		return nil, err
+	}
+	if !paused {
+		slog.InfoContext(ctx, "crawler resumed", "crawler", loop)
+		return &TGIngestResult{Summary: fmt.Sprintf("%s resumed; it runs again on its next tick (/crawl %s to run now)", loop, loop)}, nil
+	}
+	slog.InfoContext(ctx, "crawler paused", "crawler", loop)
+	summary := fmt.Sprintf("%s paused; scheduled runs are skipped until /resume %s", loop, loop)
+	if l, ok := a.schedule.loop(loop); ok && l.stop() {
+		summary += "\nthe run in progress was cancelled"
+	}
+	return &TGIngestResult{Summary: summary}, nil
+}
+
+// handleTGCrawl queues one pass of the Pixiv or Twitter author crawler on
+// its loop. With a user only that Pixiv artist id or Twitter username is
+// crawled, recorded under its own run name so one user does not stand in
+// for the whole crawler on /status. Manual passes run even while the loop
+// is paused.
+func (a *App) handleTGCrawl(ctx context.Context, args string) (*TGIngestResult, error) {
+	const usage = "Usage: /crawl pixiv|twitter [user]"
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 {
+		return &TGIngestResult{Summary: usage}, nil
+	}
+	loop := strings.ToLower(fields[0])
+	user := ""
+	if len(fields) == 2 {
+		user = fields[1]
+	}
+
+	var crawl func(ctx context.Context)
+	switch {
+	case loop == "pixiv" && user == "":
+		crawl = a.crawlPixivOnce
+	case loop == "pixiv":
+		if pixivIDNum(user) <= 0 {
+			return &TGIngestResult{Summary: "Usage: /crawl pixiv <artist id>"}, nil
+		}
+		crawl = func(ctx context.Context) {
+			a.runCrawler(ctx, "pixiv_artist_manual", func(run *crawlRun) { a.crawlPixivArtists(ctx, run, []string{user}) })
+		}
+	case loop == "twitter" && user == "":
+		crawl = func(ctx context.Context) {
+			a.runCrawler(ctx, "twitter_author", func(run *crawlRun) { a.crawlTwitterAuthorsOnce(ctx, run) })
+		}
+	case loop == "twitter":
+		if user = normalizeTwitterUsername(user); user == "" {
+			return &TGIngestResult{Summary: "Usage: /crawl twitter <username>"}, nil
+		}
+		crawl = func(ctx context.Context) {
+			a.runCrawler(ctx, "twitter_user_manual", func(run *crawlRun) { run.record(a.crawlTwitterAuthorUser(ctx, run, user)) })
+		}
+	default:
+		return &TGIngestResult{Summary: usage}, nil
+	}
+
+	l, ok := a.schedule.loop(loop)
+	if !ok {
+		return &TGIngestResult{Summary: fmt.Sprintf("%s crawler is not enabled", loop)}, nil
+	}
+	select {
+	case l.trigger <- crawl:
+	default:
+		return &TGIngestResult{Summary: fmt.Sprintf("a manual %s crawl is already queued", loop)}, nil
+	}
+	target := loop
+	if user != "" {
+		target += " " + user
+	}
+	summary := fmt.Sprintf("%s crawl queued; it starts once the current run (if any) finishes, see /status for the result", target)
+	if a.crawlerPaused(ctx, loop) {
+		summary += fmt.Sprintf("\n%s stays paused for scheduled runs", loop)
+	}
+	return &TGIngestResult{Summary: summary}, nil
+}
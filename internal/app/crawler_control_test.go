@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTGPauseCancelsRunAndSkipsTicks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, _ := newTestPublicApp(t, 0, 0)
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	a.crawlEvery(ctx, "pixiv", time.Hour, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+	}, "pixiv_bookmarks")
+	<-started
+
+	res, err := a.handleTGCommand(ctx, "pause", "pixiv")
+	if err != nil || !strings.Contains(res.Summary, "the run in progress was cancelled") {
+		t.Fatalf("/pause pixiv = %+v, %v", res, err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("paused run was not cancelled")
+	}
+	if !a.crawlerPaused(ctx, "pixiv") {
+		t.Fatal("pause flag not persisted")
+	}
+
+	// A paused loop skips its ticks until resumed.
+	var ticks atomic.Int32
+	if _, err := a.handleTGCommand(ctx, "pause", "twitter"); err != nil {
+		t.Fatalf("/pause twitter: %v", err)
+	}
+	a.crawlEvery(ctx, "twitter", 10*time.Millisecond, func(context.Context) { ticks.Add(1) }, "twitter_author")
+	time.Sleep(100 * time.Millisecond)
+	if n := ticks.Load(); n != 0 {
+		t.Fatalf("paused loop ran %d times", n)
+	}
+	if _, err := a.handleTGCommand(ctx, "resume", "twitter"); err != nil {
+		t.Fatalf("/resume twitter: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for ticks.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ticks.Load() == 0 {
+		t.Fatal("resumed loop did not run")
+	}
+}
+
+func TestTGCrawlQueuesManualRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, _ := newTestPublicApp(t, 0, 0)
+
+	if res, _ := a.handleTGCommand(ctx, "crawl", "pixiv"); !strings.Contains(res.Summary, "not enabled") {
+		t.Fatalf("/crawl without loop = %q", res.Summary)
+	}
+	if res, _ := a.handleTGCommand(ctx, "crawl", "yande"); !strings.HasPrefix(res.Summary, "Usage:") {
+		t.Fatalf("/crawl yande = %q", res.Summary)
+	}
+	if res, _ := a.handleTGCommand(ctx, "crawl", "pixiv abc"); !strings.HasPrefix(res.Summary, "Usage:") {
+		t.Fatalf("/crawl pixiv abc = %q", res.Summary)
+	}
+
+	// The scheduled run blocks, so the manual pass waits in the queue.
+	release := make(chan struct{})
+	a.crawlEvery(ctx, "twitter", time.Hour, func(context.Context) { <-release }, "twitter_author")
+	res, err := a.handleTGCommand(ctx, "crawl", "twitter @someone")
+	if err != nil || !strings.Contains(res.Summary, "twitter someone crawl queued") {
+		t.Fatalf("/crawl twitter @someone = %+v, %v", res, err)
+	}
+	if res, _ := a.handleTGCommand(ctx, "crawl", "twitter"); !strings.Contains(res.Summary, "already queued") {
+		t.Fatalf("second /crawl twitter = %q", res.Summary)
+	}
+	close(release)
+
+	// Without RSS sources the manual pass fails fast and is recorded.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		runs, err := a.DB.LatestCrawlerRuns(ctx)
+		if err != nil {
+			t.Fatalf("LatestCrawlerRuns: %v", err)
+		}
+		if len(runs) == 1 && runs[0].Crawler == "twitter_user_manual" {
+			if runs[0].Success || runs[0].Error != "no rss sources configured" {
+				t.Fatalf("manual run = %+v", runs[0])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("manual twitter crawl was not recorded")
+}
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"tyr-blog-img/internal/database"
+	"tyr-blog-img/internal/metrics"
+)
+
+// crawlRun tracks one pass of a crawler over its targets (a bookmark feed,
+// an artist, a tag query...). The run counts as failed when every target it
+// tried failed, or when images failed and none were added, so one deleted
+// artist does not mask a healthy crawler while an expired cookie or a dead
+// CDN still shows up.
+type crawlRun struct {
+	name    string
+	started time.Time
+	ok      int
+	failed  int
+	lastErr error
+
+	// pages and items are the totals recorded in crawler_runs.
+	pages int
+	items ingestStats
+}
+
+// runCrawler times crawl, reports it under name on /metrics and records it
+// in crawler_runs.
+func (a *App) runCrawler(ctx context.Context, name string, crawl func(run *crawlRun)) {
+	run := &crawlRun{name: name, started: time.Now()}
+	crawl(run)
+	ok := run.succeeded()
+	metrics.ObserveCrawl(run.name, run.started, ok)
+
+	rec := database.CrawlerRun{
+		Crawler:    run.name,
+		StartedAt:  run.started.Unix(),
+		FinishedAt: time.Now().Unix(),
+		Pages:      run.pages,
+		Added:      run.items.Downloaded,
+		Skipped:    run.items.Skipped,
+		Failed:     run.items.Failed,
+		Success:    ok,
+	}
+	if run.lastErr != nil {
+		rec.Error = run.lastErr.Error()
+	} else if !ok {
+		rec.Error = fmt.Sprintf("all %d images failed", run.items.Failed)
+	}
+	// Record runs cut short by shutdown too.
+	if err := a.DB.InsertCrawlerRun(context.WithoutCancel(ctx), rec); err != nil {
+		slog.WarnContext(ctx, "crawler run record failed", "crawler", name, "err", err)
+	}
+}
+
+// succeeded applies the rule above to the recorded outcomes.
+func (r *crawlRun) succeeded() bool {
+	if r.failed > 0 && r.ok == 0 {
+		return false
+	}
+	return r.items.Failed == 0 || r.items.Downloaded > 0
+}
+
+// record notes the outcome of one target; nil is a success.
+func (r *crawlRun) record(err error) {
+	if err != nil {
+		r.failed++
+		r.lastErr = err
+	} else {
+		r.ok++
+	}
+}
+
+// fetched counts one listing page (bookmark page, feed, tag query...).
+func (r *crawlRun) fetched() {
+	r.pages++
+}
+
+// addStats adds the image counts of one ingested post. A post that failed
+// before any image was tried counts as one failed image, and a filtered
+// post as one skipped image.
+func (r *crawlRun) addStats(stats *ingestStats, err error) {
+	switch {
+	case stats == nil:
+		if err != nil {
+			r.items.Failed++
+		}
+	case stats.SkipReason != "":
+		r.items.Skipped++
+	default:
+		r.items.Downloaded += stats.Downloaded
+		r.items.Skipped += stats.Skipped
+		r.items.Failed += stats.Failed
+	}
+}
+
+// crawlerSchedule remembers when each crawler ticks next, for /status,
+// and holds the running crawlEvery loops by name.
+type crawlerSchedule struct {
+	mu    sync.Mutex
+	next  map[string]time.Time
+	loops map[string]*crawlerLoop
+}
+
+func (s *crawlerSchedule) set(next time.Time, names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == nil {
+		s.next = make(map[string]time.Time)
+	}
+	for _, name := range names {
+		s.next[name] = next
+	}
+}
+
+func (s *crawlerSchedule) get(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next, ok := s.next[name]
+	return next, ok
+}
+
+func (s *crawlerSchedule) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.next))
+	for name := range s.next {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (s *crawlerSchedule) register(loop string) *crawlerLoop {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loops == nil {
+		s.loops = make(map[string]*crawlerLoop)
+	}
+	l := &crawlerLoop{trigger: make(chan func(ctx context.Context), 1)}
+	s.loops[loop] = l
+	return l
+}
+
+func (s *crawlerSchedule) loop(name string) (*crawlerLoop, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.loops[name]
+	return l, ok
+}
+
+// crawlerLoop is the handle of one crawlEvery loop: /crawl queues a pass on
+// trigger and /pause cancels the pass in progress.
+type crawlerLoop struct {
+	trigger chan func(ctx context.Context)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (l *crawlerLoop) run(ctx context.Context, crawl func(ctx context.Context)) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	l.mu.Lock()
+	l.cancel = cancel
+	l.mu.Unlock()
+	crawl(runCtx)
+	l.mu.Lock()
+	l.cancel = nil
+	l.mu.Unlock()
+}
+
+// stop cancels the pass in progress and reports whether there was one.
+func (l *crawlerLoop) stop() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cancel == nil {
+		return false
+	}
+	l.cancel()
+	return true
+}
+
+// crawlEvery runs crawl now and then every interval until ctx is done,
+// skipping ticks while the loop is paused (see /pause). Manual passes queued
+// by /crawl run in between ticks, so they never overlap a scheduled one.
+// names are the crawlers the loop drives; /status shows their next tick.
+func (a *App) crawlEvery(ctx context.Context, loop string, interval time.Duration, crawl func(ctx context.Context), names ...string) {
+	l := a.schedule.register(loop)
+	scheduled := func() {
+		if a.crawlerPaused(ctx, loop) {
+			slog.InfoContext(ctx, "crawler paused, run skipped", "crawler", loop)
+			return
+		}
+		l.run(ctx, crawl)
+	}
+	go func() {
+		// The ticker starts after the first run, so this is an estimate
+		// until then.
+		a.schedule.set(time.Now().Add(interval), names...)
+		scheduled()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		a.schedule.set(time.Now().Add(interval), names...)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case tick := <-ticker.C:
+				a.schedule.set(tick.Add(interval), names...)
+				scheduled()
+			case manual := <-l.trigger:
+				slog.InfoContext(ctx, "manual crawl started", "crawler", loop)
+				l.run(ctx, manual)
+			}
+		}
+	}()
+}
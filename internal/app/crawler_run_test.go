@@ -0,0 +1,33 @@
+package app
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCrawlRunSucceeded(t *testing.T) {
+	cases := []struct {
+		name string
+		run  crawlRun
+		want bool
+	}{
+		{"empty", crawlRun{}, true},
+		{"one target ok", crawlRun{ok: 1, failed: 2}, true},
+		{"all targets failed", crawlRun{failed: 2}, false},
+		{"items failed, none added", crawlRun{ok: 3, items: ingestStats{Failed: 4, Skipped: 2}}, false},
+		{"items failed, some added", crawlRun{ok: 3, items: ingestStats{Failed: 4, Downloaded: 1}}, true},
+		{"nothing new", crawlRun{ok: 3, items: ingestStats{Skipped: 5}}, true},
+	}
+	for _, tc := range cases {
+		if got := tc.run.succeeded(); got != tc.want {
+			t.Fatalf("%s: succeeded = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	run := crawlRun{}
+	run.record(errors.New("cookie expired"))
+	run.record(nil)
+	if !run.succeeded() {
+		t.Fatal("a run with one ok target should succeed")
+	}
+}
@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	danbooruBaseURL          = "https://danbooru.donmai.us"
+	danbooruPostsPerRequest  = 100
+	danbooruPoolLinkIDPrefix = "pool:"
+)
+
+// danbooruSite is Danbooru as a booru flavour. It is not in booruSites
+// because its links (pools) and API login are handled here.
+var danbooruSite = booruSite{
+	Name:         "Danbooru",
+	BaseURL:      danbooruBaseURL,
+	Flavor:       booruDanbooru,
+	Referer:      danbooruBaseURL + "/",
+	SourcePrefix: "danbooru",
+}
+
+type danbooruPost struct {
+	ID           int    `json:"id"`
+	ParentID     *int   `json:"parent_id"`
+	HasChildren  bool   `json:"has_children"`
+	FileExt      string `json:"file_ext"`
+	FileURL      string `json:"file_url"`
+	LargeFileURL string `json:"large_file_url"`
+	TagString    string `json:"tag_string"`
+	// TagStringArtist holds the artist tags, which double as the author.
+	TagStringArtist string `json:"tag_string_artist"`
+}
+
+type danbooruPool struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	PostIDs []int  `json:"post_ids"`
+}
+
+// isImage filters out ugoira zips and videos, which the gallery cannot store.
+func (p danbooruPost) isImage() bool {
+	switch strings.ToLower(strings.TrimSpace(p.FileExt)) {
+	case "jpg", "jpeg", "png", "gif", "webp", "avif":
+		return true
+	case "":
+		// Restricted posts omit file fields entirely; let the URL check decide.
+		return true
+	default:
+		return false
+	}
+}
+
+func (p danbooruPost) toBooruPost() booruPost {
+	out := booruPost{
+		ID:          p.ID,
+		HasChildren: p.HasChildren,
+		URLs:        normalizeBooruURLs(p.FileURL, p.LargeFileURL),
+		Tags:        strings.Fields(p.TagString),
+		Artists:     strings.Fields(p.TagStringArtist),
+		NotImage:    !p.isImage(),
+	}
+	if p.ParentID != nil {
+		out.ParentID = *p.ParentID
+	}
+	return out
+}
+
+func (a *App) ingestDanbooruFromLink(ctx context.Context, item supportedLink) (*TGIngestResult, error) {
+	var (
+		posts []booruPost
+		label = "Danbooru " + item.ID
+		err   error
+	)
+	if poolID, ok := strings.CutPrefix(item.ID, danbooruPoolLinkIDPrefix); ok {
+		var pool *danbooruPool
+		pool, posts, err = a.fetchDanbooruPoolPosts(ctx, poolID)
+		if err == nil && pool.Name != "" {
+			label = fmt.Sprintf("Danbooru pool %s (%s)", poolID, strings.ReplaceAll(pool.Name, "_", " "))
+		}
+	} else {
+		posts, err = a.fetchDanbooruFamilyPosts(ctx, item.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("danbooru post not found")
+	}
+	stats, err := a.ingestBooruPosts(ctx, danbooruSite, posts)
+	if err != nil {
+		return nil, err
+	}
+	return &TGIngestResult{
+		ID:        stats.FirstID,
+		Title:     stats.Title,
+		SourceURL: item.URL,
+		Summary:   fmt.Sprintf("%s done: +%d, skipped %d, failed %d", label, stats.Downloaded, stats.Skipped, stats.Failed),
+		Stats:     stats,
+	}, nil
+}
+
+func danbooruAPIURL(path string, query neturl.Values) string {
+	u := danbooruSite.BaseURL + path
+	if encoded := query.Encode(); encoded != "" {
+		u += "?" + encoded
+	}
+	return u
+}
+
+// danbooruAuth is login/api_key when configured, sent as basic auth so the
+// key never shows up in a URL or an error; anonymous requests are limited
+// to two search tags.
+func (a *App) danbooruAuth() downloadAuth {
+	if a.Cfg == nil || a.Cfg.DanbooruLogin == "" || a.Cfg.DanbooruAPIKey == "" {
+		return downloadAuth{}
+	}
+	return downloadAuth{user: a.Cfg.DanbooruLogin, password: a.Cfg.DanbooruAPIKey}
+}
+
+func (a *App) fetchDanbooruJSON(ctx context.Context, path string, query neturl.Values, out interface{}) error {
+	body, err := a.fetchDanbooruBody(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (a *App) fetchDanbooruBody(ctx context.Context, path string, query neturl.Values) ([]byte, error) {
+	return downloadWithAuthRetry(ctx, danbooruAPIURL(path, query), danbooruSite.Referer, a.danbooruAuth(), booruAPITimeout, booruAPIRetries, booruRetryBackoff)
+}
+
+func (a *App) fetchDanbooruPosts(ctx context.Context, tags string, limit int) ([]booruPost, error) {
+	tags = strings.TrimSpace(tags)
+	if tags == "" {
+		return nil, fmt.Errorf("danbooru tags is empty")
+	}
+	query := neturl.Values{"tags": {tags}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	body, err := a.fetchDanbooruBody(ctx, "/posts.json", query)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBooruPosts(danbooruSite, body)
+}
+
+func (a *App) fetchDanbooruPost(ctx context.Context, id string) (*booruPost, error) {
+	var post danbooruPost
+	if err := a.fetchDanbooruJSON(ctx, fmt.Sprintf("/posts/%s.json", neturl.PathEscape(strings.TrimSpace(id))), nil, &post); err != nil {
+		return nil, err
+	}
+	if post.ID == 0 {
+		return nil, fmt.Errorf("danbooru post not found")
+	}
+	out := post.toBooruPost()
+	return &out, nil
+}
+
+// fetchDanbooruFamilyPosts mirrors fetchBooruFamilyPosts: the seed post plus
+// its parent and every sibling, ordered by id.
+func (a *App) fetchDanbooruFamilyPosts(ctx context.Context, id string) ([]booruPost, error) {
+	seed, err := a.fetchDanbooruPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rootID := seed.ID
+	if seed.ParentID > 0 {
+		rootID = seed.ParentID
+	} else if !seed.HasChildren {
+		return []booruPost{*seed}, nil
+	}
+	// parent:N matches N itself and all of its children.
+	family, err := a.fetchDanbooruPosts(ctx, fmt.Sprintf("parent:%d", rootID), 200)
+	if err != nil || len(family) == 0 {
+		return []booruPost{*seed}, nil
+	}
+	merged := make(map[int]booruPost, len(family)+1)
+	for _, p := range family {
+		merged[p.ID] = p
+	}
+	merged[seed.ID] = *seed
+	out := make([]booruPost, 0, len(merged))
+	for _, p := range merged {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// fetchDanbooruPoolPosts returns the pool and its posts in pool order.
+func (a *App) fetchDanbooruPoolPosts(ctx context.Context, id string) (*danbooruPool, []booruPost, error) {
+	var pool danbooruPool
+	if err := a.fetchDanbooruJSON(ctx, fmt.Sprintf("/pools/%s.json", neturl.PathEscape(strings.TrimSpace(id))), nil, &pool); err != nil {
+		return nil, nil, err
+	}
+	if pool.ID == 0 {
+		return nil, nil, fmt.Errorf("danbooru pool not found")
+	}
+
+	byID := make(map[int]booruPost, len(pool.PostIDs))
+	for start := 0; start < len(pool.PostIDs); start += danbooruPostsPerRequest {
+		end := start + danbooruPostsPerRequest
+		if end > len(pool.PostIDs) {
+			end = len(pool.PostIDs)
+		}
+		ids := make([]string, 0, end-start)
+		for _, pid := range pool.PostIDs[start:end] {
+			ids = append(ids, strconv.Itoa(pid))
+		}
+		chunk, err := a.fetchDanbooruPosts(ctx, "id:"+strings.Join(ids, ","), len(ids))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range chunk {
+			byID[p.ID] = p
+		}
+	}
+
+	out := make([]booruPost, 0, len(byID))
+	for _, pid := range pool.PostIDs {
+		if p, ok := byID[pid]; ok {
+			out = append(out, p)
+		}
+	}
+	return &pool, out, nil
+}
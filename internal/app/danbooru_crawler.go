@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const danbooruTagStatePrefix = "danbooru_tag_last_"
+
+// StartDanbooruCrawler polls each configured tag query and ingests posts
+// newer than the last one it saw for that query.
+func (a *App) StartDanbooruCrawler(ctx context.Context) {
+	if a.Cfg == nil || !a.Cfg.HasDanbooruCrawler() {
+		slog.InfoContext(ctx, "danbooru crawler disabled")
+		return
+	}
+	crawl := func(ctx context.Context) {
+		a.runCrawler(ctx, "danbooru", func(run *crawlRun) { a.crawlDanbooruOnce(ctx, run) })
+	}
+	interval := time.Duration(maxInt(a.Cfg.DanbooruIntervalMin, 60)) * time.Minute
+	a.crawlEvery(ctx, "danbooru", interval, crawl, "danbooru")
+}
+
+// crawlDanbooruOnce fetches DanbooruFetchLimit posts per query and ingests
+// all the new ones.
+func (a *App) crawlDanbooruOnce(ctx context.Context, run *crawlRun) {
+	fetch := func(ctx context.Context, query string) ([]booruPost, error) {
+		return a.fetchDanbooruPosts(ctx, query, maxInt(a.Cfg.DanbooruFetchLimit, 20))
+	}
+	a.crawlBooruTagsOnce(ctx, run, danbooruSite, danbooruTagStatePrefix, a.Cfg.DanbooruTags, 0, fetch)
+}
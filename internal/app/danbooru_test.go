@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tyr-blog-img/internal/config"
+)
+
+func TestExtractSupportedLinksDanbooru(t *testing.T) {
+	links := extractSupportedLinks(
+		"https://danbooru.donmai.us/posts/7654321?q=ganyu",
+		"https://danbooru.donmai.us/pools/12345",
+		"https://danbooru.donmai.us/posts?tags=ganyu",
+	)
+	if len(links) != 2 {
+		t.Fatalf("links len = %d, want 2: %#v", len(links), links)
+	}
+	if links[0].Type != linkDanbooru || links[0].ID != "7654321" {
+		t.Fatalf("post link = %#v, want danbooru post id", links[0])
+	}
+	if links[1].Type != linkDanbooru || links[1].ID != "pool:12345" {
+		t.Fatalf("pool link = %#v, want danbooru pool id", links[1])
+	}
+}
+
+func TestDanbooruPostSkipsNonImages(t *testing.T) {
+	for ext, want := range map[string]bool{"jpg": true, "png": true, "mp4": false, "zip": false} {
+		if got := (danbooruPost{FileExt: ext}).isImage(); got != want {
+			t.Fatalf("isImage(%s) = %v, want %v", ext, got, want)
+		}
+	}
+}
+
+func TestDecodeBooruPostsDanbooruFlavour(t *testing.T) {
+	posts, err := decodeBooruPosts(danbooruSite, []byte(`[
+		{"id":11,"parent_id":10,"file_ext":"png","file_url":"https://cdn.donmai.us/original/a.png","large_file_url":"https://cdn.donmai.us/sample/a.jpg","tag_string":"ganyu solo","tag_string_artist":"kantoku"},
+		{"id":12,"file_ext":"mp4","file_url":"https://cdn.donmai.us/original/b.mp4"},
+		{"id":13,"file_ext":"jpg"}
+	]`))
+	if err != nil || len(posts) != 3 {
+		t.Fatalf("danbooru decode = %#v, %v", posts, err)
+	}
+	if p := posts[0]; p.ID != 11 || p.ParentID != 10 || p.NotImage || len(p.URLs) != 2 || len(p.Tags) != 2 || len(p.Artists) != 1 || p.Artists[0] != "kantoku" {
+		t.Fatalf("image post = %#v", p)
+	}
+	if !posts[1].NotImage {
+		t.Fatalf("video post = %#v, want NotImage", posts[1])
+	}
+	// Restricted posts decode without URLs and are skipped at ingest.
+	if p := posts[2]; p.NotImage || len(p.URLs) != 0 {
+		t.Fatalf("restricted post = %#v", p)
+	}
+	if got := danbooruSite.postURL(11); got != "https://danbooru.donmai.us/posts/11" {
+		t.Fatalf("postURL = %s", got)
+	}
+}
+
+func TestDanbooruAPIKeyStaysOutOfErrors(t *testing.T) {
+	const key = "s3cret-api-key"
+	var gotUser, gotKey, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, gotKey, _ = r.BasicAuth()
+		gotQuery = r.URL.RawQuery
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	site := danbooruSite
+	t.Cleanup(func() { danbooruSite = site })
+	danbooruSite.BaseURL = srv.URL
+
+	a := &App{Cfg: &config.Config{DanbooruLogin: "alice", DanbooruAPIKey: key}}
+	_, err := a.fetchDanbooruPosts(context.Background(), "ganyu", 20)
+	if err == nil || strings.Contains(err.Error(), key) {
+		t.Fatalf("status error = %v, want one without the key", err)
+	}
+	if gotUser != "alice" || gotKey != key || strings.Contains(gotQuery, key) {
+		t.Fatalf("request auth = %q/%q, query %q; want basic auth only", gotUser, gotKey, gotQuery)
+	}
+
+	// Transport errors print the URL.
+	srv.Close()
+	if _, err := a.fetchDanbooruPost(context.Background(), "1"); err == nil || strings.Contains(err.Error(), key) {
+		t.Fatalf("transport error = %v, want one without the key", err)
+	}
+}
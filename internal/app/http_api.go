@@ -0,0 +1,334 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tyr-blog-img/internal/database"
+)
+
+const (
+	apiListDefaultLimit = 50
+	apiListMaxLimit     = 200
+)
+
+// RegisterAPIHandlers serves the token-protected /api/v1 endpoints used by
+// teammates and scripts without Telegram access. Nothing is registered when
+// API_TOKEN is empty.
+func (a *App) RegisterAPIHandlers(mux *http.ServeMux) {
+	if a.Cfg == nil || !a.Cfg.HasAPI() {
+		slog.Info("HTTP API disabled (API_TOKEN not set)")
+		return
+	}
+	mux.Handle("GET /api/v1/images", a.requireAPIToken(a.handleAPIListImages))
+	mux.Handle("GET /api/v1/images/id/{id}", a.requireAPIToken(a.handleAPIGetImageByID))
+	mux.Handle("GET /api/v1/images/{o}/{seq}", a.requireAPIToken(a.handleAPIGetImage))
+	mux.Handle("PATCH /api/v1/images/{o}/{seq}", a.requireAPIToken(a.handleAPIPatchImage))
+	mux.Handle("DELETE /api/v1/images/{o}/{seq}", a.requireAPIToken(a.handleAPIDeleteImage))
+	mux.Handle("POST /api/v1/ingest", a.requireAPIToken(a.handleAPIIngest))
+}
+
+// requireAPIToken accepts "Authorization: Bearer <API_TOKEN>".
+func (a *App) requireAPIToken(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(a.Cfg.APIToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tyr-blog-img"`)
+			writeAPIError(w, http.StatusUnauthorized, "invalid or missing API token")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// apiImage is the JSON view of a gallery_images row.
+type apiImage struct {
+	ID           string   `json:"id"`
+	Source       string   `json:"source"`
+	SourceKey    string   `json:"source_key"`
+	SourceURL    string   `json:"source_url,omitempty"`
+	SourcePostID string   `json:"source_post_id,omitempty"`
+	SHA256       string   `json:"sha256"`
+	PHash        string   `json:"phash,omitempty"`
+	Orientation  string   `json:"orientation"`
+	Seq          int64    `json:"seq"`
+	R2Key        string   `json:"r2_key"`
+	URL          string   `json:"url,omitempty"`
+	Width        int      `json:"width"`
+	Height       int      `json:"height"`
+	Bytes        int64    `json:"bytes"`
+	MimeType     string   `json:"mime_type"`
+	PublishedAt  int64    `json:"published_at"`
+	CollectedAt  int64    `json:"collected_at"`
+	Status       string   `json:"status"`
+	Title        string   `json:"title,omitempty"`
+	AuthorName   string   `json:"author_name,omitempty"`
+	AuthorID     string   `json:"author_id,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+func (a *App) toAPIImage(img database.GalleryImage) apiImage {
+	out := apiImage{
+		ID:           img.ID,
+		Source:       img.Source,
+		SourceKey:    img.SourceKey,
+		SourceURL:    img.SourceURL,
+		SourcePostID: img.SourcePostID,
+		SHA256:       img.SHA256,
+		PHash:        img.PHash,
+		Orientation:  img.Orientation,
+		Seq:          img.Seq,
+		R2Key:        img.R2Key,
+		Width:        img.Width,
+		Height:       img.Height,
+		Bytes:        img.Bytes,
+		MimeType:     img.MimeType,
+		PublishedAt:  img.PublishedAt,
+		CollectedAt:  img.CollectedAt,
+		Status:       img.Status,
+		Title:        img.Title,
+		AuthorName:   img.AuthorName,
+		AuthorID:     img.AuthorID,
+	}
+	if base := a.imageBaseURL(); base != "" && img.Status == "active" {
+		out.URL = base + "/" + img.R2Key
+	}
+	if img.Seq < 1 {
+		// Removed rows gave up their slot; they are addressed by id.
+		out.Seq = 0
+	}
+	return out
+}
+
+// handleAPIListImages lists images newest first. Query parameters: source,
+// o, status, from/to (collected_at unix seconds, to exclusive), limit and
+// cursor (next_cursor of the previous page).
+func (a *App) handleAPIListImages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := database.GalleryImageFilter{
+		Source:      q.Get("source"),
+		Orientation: q.Get("o"),
+		Status:      q.Get("status"),
+		Limit:       apiListDefaultLimit,
+	}
+	if o := strings.TrimSpace(f.Orientation); o != "" && o != "h" && o != "v" {
+		writeAPIError(w, http.StatusBadRequest, "o must be h or v")
+		return
+	}
+	var err error
+	if f.CollectedFrom, err = parseAPIInt(q.Get("from")); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "from must be a unix timestamp")
+		return
+	}
+	if f.CollectedTo, err = parseAPIInt(q.Get("to")); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "to must be a unix timestamp")
+		return
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		f.Limit = min(n, apiListMaxLimit)
+	}
+	if raw := q.Get("cursor"); raw != "" {
+		if f.AfterCollectedAt, f.AfterID, err = decodeAPICursor(raw); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	// Ask for one extra row to know whether another page exists.
+	limit := f.Limit
+	f.Limit++
+	images, err := a.DB.ListGalleryImages(r.Context(), f)
+	if err != nil {
+		slog.Error("api list images failed", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "list images failed")
+		return
+	}
+	resp := struct {
+		Items      []apiImage `json:"items"`
+		NextCursor string     `json:"next_cursor,omitempty"`
+	}{Items: make([]apiImage, 0, min(len(images), limit))}
+	if len(images) > limit {
+		images = images[:limit]
+		last := images[len(images)-1]
+		resp.NextCursor = encodeAPICursor(last.CollectedAt, last.ID)
+	}
+	for _, img := range images {
+		resp.Items = append(resp.Items, a.toAPIImage(img))
+	}
+	writeAPIJSON(w, http.StatusOK, resp)
+}
+
+func (a *App) handleAPIGetImage(w http.ResponseWriter, r *http.Request) {
+	img, ok := a.apiLookupImage(w, r)
+	if !ok {
+		return
+	}
+	a.writeAPIImageDetail(w, r, img)
+}
+
+// handleAPIGetImageByID also finds removed images, which no longer hold a
+// seq.
+func (a *App) handleAPIGetImageByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.PathValue("id"))
+	img, ok, err := a.DB.GetGalleryImageByID(r.Context(), id)
+	if err != nil {
+		slog.Error("api get image failed", "id", id, "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "lookup failed")
+		return
+	}
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	a.writeAPIImageDetail(w, r, img)
+}
+
+func (a *App) writeAPIImageDetail(w http.ResponseWriter, r *http.Request, img database.GalleryImage) {
+	out := a.toAPIImage(img)
+	tags, err := a.DB.ListGalleryImageTags(r.Context(), img.ID)
+	if err != nil {
+		slog.Warn("api image tags failed", "id", img.ID, "err", err)
+	}
+	out.Tags = tags
+	writeAPIJSON(w, http.StatusOK, out)
+}
+
+// handleAPIPatchImage changes the status of an active image. Seqs must stay
+// contiguous, so the only transition is "removed": the same takedown as
+// DELETE minus the blocklist entry. The removed row keeps its source key,
+// sha256 and phash, so the source is still skipped as a duplicate.
+func (a *App) handleAPIPatchImage(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	img, ok := a.apiLookupImage(w, r)
+	if !ok {
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(body.Status)) {
+	case "active":
+		writeAPIJSON(w, http.StatusOK, a.toAPIImage(img))
+	case "removed":
+		a.apiTakeDown(w, r, img, "api_patch", body.Reason, false)
+	default:
+		writeAPIError(w, http.StatusBadRequest, `status must be "active" or "removed"`)
+	}
+}
+
+// handleAPIDeleteImage takes an image down and blocklists its source key,
+// like the /del bot command.
+func (a *App) handleAPIDeleteImage(w http.ResponseWriter, r *http.Request) {
+	img, ok := a.apiLookupImage(w, r)
+	if !ok {
+		return
+	}
+	a.apiTakeDown(w, r, img, "api_del", r.URL.Query().Get("reason"), true)
+}
+
+func (a *App) apiTakeDown(w http.ResponseWriter, r *http.Request, img database.GalleryImage, prefix, reason string, block bool) {
+	if img.Status != "active" {
+		writeAPIError(w, http.StatusConflict, "image is not active")
+		return
+	}
+	if reason = strings.TrimSpace(reason); reason != "" {
+		reason = prefix + ": " + reason
+	} else {
+		reason = prefix
+	}
+	res, err := a.Gallery.TakeDownImage(r.Context(), img.Orientation, img.Seq, reason, block)
+	if err != nil {
+		slog.Error("api takedown failed", "orientation", img.Orientation, "seq", img.Seq, "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "takedown failed")
+		return
+	}
+	resp := struct {
+		Removed  apiImage `json:"removed"`
+		Blocked  bool     `json:"blocked"`
+		MovedSeq int64    `json:"moved_from_seq,omitempty"`
+		Metadata string   `json:"metadata"`
+	}{Removed: a.toAPIImage(res.Removed), Blocked: block}
+	resp.Removed.Status = "removed"
+	resp.Removed.URL = ""
+	if res.Moved != nil {
+		resp.MovedSeq = res.MovedSeq
+	}
+	// Republish counts so random*.js stops picking the freed tail seq.
+	if summary, err := a.publishMetadata(r.Context()); err != nil {
+		slog.Error("api takedown metadata publish failed", "err", err)
+		resp.Metadata = "metadata update failed"
+	} else {
+		resp.Metadata = summary
+	}
+	writeAPIJSON(w, http.StatusOK, resp)
+}
+
+func (a *App) apiLookupImage(w http.ResponseWriter, r *http.Request) (database.GalleryImage, bool) {
+	o := strings.ToLower(r.PathValue("o"))
+	seq, err := strconv.ParseInt(r.PathValue("seq"), 10, 64)
+	if (o != "h" && o != "v") || err != nil || seq < 1 {
+		writeAPIError(w, http.StatusBadRequest, "path must be /api/v1/images/{h|v}/{seq}")
+		return database.GalleryImage{}, false
+	}
+	img, ok, err := a.DB.GetGalleryImage(r.Context(), o, seq)
+	if err != nil {
+		slog.Error("api get image failed", "orientation", o, "seq", seq, "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "lookup failed")
+		return database.GalleryImage{}, false
+	}
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "image not found")
+		return database.GalleryImage{}, false
+	}
+	return img, true
+}
+
+func parseAPIInt(raw string) (int64, error) {
+	if raw = strings.TrimSpace(raw); raw == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+// API cursors are opaque to clients: base64url of "collected_at:id".
+func encodeAPICursor(collectedAt int64, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(collectedAt, 10) + ":" + id))
+}
+
+func decodeAPICursor(raw string) (int64, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return 0, "", err
+	}
+	ts, id, ok := strings.Cut(string(b), ":")
+	if !ok || id == "" {
+		return 0, "", strconv.ErrSyntax
+	}
+	n, err := strconv.ParseInt(ts, 10, 64)
+	return n, id, err
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeAPIJSON(w, status, map[string]string{"error": msg})
+}
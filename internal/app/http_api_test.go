@@ -0,0 +1,251 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/storage"
+)
+
+func newTestAPIApp(t *testing.T, h int) (*App, *http.ServeMux) {
+	t.Helper()
+	a, mux := newTestPublicApp(t, h, 0)
+	fs, err := storage.NewFSClient(storage.FSConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFSClient: %v", err)
+	}
+	for seq := 1; seq <= h; seq++ {
+		if err := fs.PutObject(context.Background(), fmt.Sprintf("ri/h/%d.webp", seq), []byte("RIFF"), "image/webp"); err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+	}
+	a.Gallery = gallery.NewService(a.DB, fs, nil)
+	a.Cfg.APIToken = "secret"
+	a.RegisterAPIHandlers(mux)
+	return a, mux
+}
+
+func apiRequest(t *testing.T, mux *http.ServeMux, method, target, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode %s: %v", method, target, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	_, mux := newTestAPIApp(t, 1)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/images", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+}
+
+func TestAPIListImagesPaginates(t *testing.T) {
+	_, mux := newTestAPIApp(t, 3)
+
+	var page struct {
+		Items      []apiImage `json:"items"`
+		NextCursor string     `json:"next_cursor"`
+	}
+	if code := apiRequest(t, mux, http.MethodGet, "/api/v1/images?o=h&limit=2", "", &page); code != http.StatusOK {
+		t.Fatalf("list status = %d", code)
+	}
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("first page = %d items, cursor %q", len(page.Items), page.NextCursor)
+	}
+	seen := map[string]bool{page.Items[0].ID: true, page.Items[1].ID: true}
+
+	cursor := page.NextCursor
+	page.Items, page.NextCursor = nil, ""
+	if code := apiRequest(t, mux, http.MethodGet, "/api/v1/images?o=h&limit=2&cursor="+cursor, "", &page); code != http.StatusOK {
+		t.Fatalf("second page status = %d", code)
+	}
+	if len(page.Items) != 1 || page.NextCursor != "" || seen[page.Items[0].ID] {
+		t.Fatalf("second page = %+v, cursor %q", page.Items, page.NextCursor)
+	}
+
+	if code := apiRequest(t, mux, http.MethodGet, "/api/v1/images?o=x", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("bad orientation status = %d, want 400", code)
+	}
+}
+
+func TestAPIDeleteBlocksAndBackfills(t *testing.T) {
+	a, mux := newTestAPIApp(t, 3)
+
+	var img apiImage
+	if code := apiRequest(t, mux, http.MethodGet, "/api/v1/images/h/2", "", &img); code != http.StatusOK || img.Seq != 2 {
+		t.Fatalf("get = %d, %+v", code, img)
+	}
+	if img.URL != "https://img.example.com/ri/h/2.webp" {
+		t.Fatalf("url = %q", img.URL)
+	}
+	if code := apiRequest(t, mux, http.MethodPatch, "/api/v1/images/h/2", `{"status":"hidden"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("patch unsupported status = %d, want 400", code)
+	}
+
+	var del struct {
+		Removed  apiImage `json:"removed"`
+		Blocked  bool     `json:"blocked"`
+		MovedSeq int64    `json:"moved_from_seq"`
+	}
+	if code := apiRequest(t, mux, http.MethodDelete, "/api/v1/images/h/1?reason=dup", "", &del); code != http.StatusOK {
+		t.Fatalf("delete status = %d", code)
+	}
+	if del.Removed.SourceKey != "h_1" || !del.Blocked || del.MovedSeq != 3 {
+		t.Fatalf("delete = %+v", del)
+	}
+	if blocked, _ := a.DB.IsBlocked(context.Background(), "h_1"); !blocked {
+		t.Fatal("source key not blocklisted")
+	}
+	if code := apiRequest(t, mux, http.MethodGet, "/api/v1/images/h/3", "", nil); code != http.StatusNotFound {
+		t.Fatalf("tail seq after move status = %d, want 404", code)
+	}
+
+	// PATCH removed takes down without blocking.
+	if code := apiRequest(t, mux, http.MethodPatch, "/api/v1/images/h/2", `{"status":"removed"}`, &del); code != http.StatusOK || del.Blocked {
+		t.Fatalf("patch removed = %d, %+v", code, del)
+	}
+	if blocked, _ := a.DB.IsBlocked(context.Background(), "h_2"); blocked {
+		t.Fatal("PATCH removed should not blocklist")
+	}
+
+	// Removed rows are listed without a seq and looked up by id.
+	var list struct {
+		Items []apiImage `json:"items"`
+	}
+	if code := apiRequest(t, mux, http.MethodGet, "/api/v1/images?status=removed", "", &list); code != http.StatusOK || len(list.Items) != 2 {
+		t.Fatalf("list removed = %d, %+v", code, list)
+	}
+	for _, item := range list.Items {
+		var got apiImage
+		if code := apiRequest(t, mux, http.MethodGet, "/api/v1/images/id/"+item.ID, "", &got); code != http.StatusOK || got.Status != "removed" || got.Seq != 0 || got.URL != "" {
+			t.Fatalf("get removed %s = %d, %+v", item.ID, code, got)
+		}
+	}
+	if code := apiRequest(t, mux, http.MethodGet, "/api/v1/images/id/missing", "", nil); code != http.StatusNotFound {
+		t.Fatalf("get unknown id = %d, want 404", code)
+	}
+}
+
+// fakeUploadProcessor skips decoding and cwebp so tests can upload any bytes.
+type fakeUploadProcessor struct{}
+
+func (fakeUploadProcessor) Prepare(_ context.Context, data []byte) (gallery.PreparedImage, error) {
+	sum := sha256.Sum256(data)
+	return gallery.PreparedImage{
+		WebPBytes:   data,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Width:       1600,
+		Height:      900,
+		Orientation: "h",
+		Bytes:       int64(len(data)),
+		ContentType: "image/webp",
+	}, nil
+}
+
+func TestAPIIngestUpload(t *testing.T) {
+	a, mux := newTestAPIApp(t, 0)
+	a.Gallery.Processor = fakeUploadProcessor{}
+	a.Gallery.VariantWidths = nil
+
+	upload := func() apiIngestResult {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("title", "sunset")
+		fw, _ := mw.CreateFormFile("file", "sunset.webp")
+		_, _ = fw.Write([]byte("fake image bytes"))
+		_ = mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest", &body)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ingest status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Results []apiIngestResult `json:"results"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Results) != 1 {
+			t.Fatalf("ingest body %s: %v", rec.Body.String(), err)
+		}
+		return resp.Results[0]
+	}
+
+	first := upload()
+	if !first.Added || first.Field != "file" || first.Image == nil || first.Image.Seq != 1 || first.Image.Title != "sunset" || first.Image.Source != "upload" {
+		t.Fatalf("first upload = %+v", first)
+	}
+	if again := upload(); again.Added || again.SkipReason == "" {
+		t.Fatalf("repeat upload = %+v, want skipped", again)
+	}
+}
+
+func TestAPIIngestUploadKeepsPartOrder(t *testing.T) {
+	a, mux := newTestAPIApp(t, 0)
+	a.Gallery.Processor = fakeUploadProcessor{}
+	a.Gallery.VariantWidths = nil
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range [][2]string{{"b", "3.webp"}, {"a", "1.webp"}, {"a", "2.webp"}} {
+		fw, _ := mw.CreateFormFile(part[0], part[1])
+		_, _ = fw.Write([]byte("image " + part[1]))
+	}
+	// Text fields apply to every file, even when sent last.
+	_ = mw.WriteField("title", "batch")
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest", &body)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	var resp struct {
+		Results []apiIngestResult `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK || len(resp.Results) != 3 {
+		t.Fatalf("ingest = %d %s: %v", rec.Code, rec.Body.String(), err)
+	}
+	for i, want := range []string{"b 3.webp", "a 1.webp", "a 2.webp"} {
+		res := resp.Results[i]
+		if got := res.Field + " " + res.File; got != want || res.Image == nil || res.Image.Seq != int64(i+1) || res.Image.Title != "batch" {
+			t.Fatalf("result %d = %s seq %+v, want %s at seq %d", i, got, res.Image, want, i+1)
+		}
+	}
+}
+
+func TestAPIIngestRejectsUnsupportedURLs(t *testing.T) {
+	_, mux := newTestAPIApp(t, 0)
+	if code := apiRequest(t, mux, http.MethodPost, "/api/v1/ingest", `{"urls":["https://example.com/a.png"]}`, nil); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status without content type = %d, want 415", code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest", strings.NewReader(`{"urls":["https://example.com/a.png"]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+}
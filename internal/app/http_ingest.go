@@ -0,0 +1,192 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"tyr-blog-img/internal/database"
+	"tyr-blog-img/internal/gallery"
+)
+
+const (
+	apiIngestMaxBody  = 64 << 20
+	apiIngestMaxField = 64 << 10
+	apiIngestMaxLinks = 10
+)
+
+// apiIngestResult is one uploaded file or link. File results mirror
+// gallery.StoreResult; link results carry the same summary the bot replies
+// with.
+type apiIngestResult struct {
+	Field string `json:"field,omitempty"`
+	File  string `json:"file,omitempty"`
+	URL   string `json:"url,omitempty"`
+
+	Added       bool                    `json:"added"`
+	SkipReason  string                  `json:"skip_reason,omitempty"`
+	ContentHash string                  `json:"content_hash,omitempty"`
+	Image       *apiImage               `json:"image,omitempty"`
+	Counts      *database.GalleryCounts `json:"counts,omitempty"`
+
+	ID      string           `json:"id,omitempty"`
+	Title   string           `json:"title,omitempty"`
+	Summary string           `json:"summary,omitempty"`
+	Images  *apiIngestImages `json:"images,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// apiIngestImages are the image counts of one link, which may hold several.
+type apiIngestImages struct {
+	Added   int `json:"added"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// handleAPIIngest accepts either multipart/form-data image uploads (every
+// file part is stored; optional source_url and title fields apply to all)
+// or a JSON body {"urls": [...]} routed like links sent to the bot.
+// Upload results follow the order the files were sent in and echo each
+// part's field and file name.
+func (a *App) handleAPIIngest(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, apiIngestMaxBody)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var (
+		results []apiIngestResult
+		status  int
+		errMsg  string
+	)
+	switch mediaType {
+	case "multipart/form-data":
+		results, status, errMsg = a.apiIngestUploads(r)
+	case "application/json":
+		results, status, errMsg = a.apiIngestURLs(r)
+	default:
+		status, errMsg = http.StatusUnsupportedMediaType, "use multipart/form-data uploads or a JSON body with urls"
+	}
+	if errMsg != "" {
+		writeAPIError(w, status, errMsg)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, map[string][]apiIngestResult{"results": results})
+}
+
+func (a *App) apiIngestUploads(r *http.Request) ([]apiIngestResult, int, string) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, http.StatusBadRequest, "invalid multipart body: " + err.Error()
+	}
+	// Read every part first, in the order sent: the text fields apply to
+	// all files and may come after them.
+	var (
+		uploads          []apiIngestResult
+		files            [][]byte
+		sourceURL, title string
+	)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, http.StatusBadRequest, "invalid multipart body: " + err.Error()
+		}
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, apiIngestMaxField))
+			if err != nil {
+				return nil, http.StatusBadRequest, "invalid multipart body: " + err.Error()
+			}
+			switch part.FormName() {
+			case "source_url":
+				sourceURL = strings.TrimSpace(string(value))
+			case "title":
+				title = strings.TrimSpace(string(value))
+			}
+			continue
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, http.StatusBadRequest, "invalid multipart body: " + err.Error()
+		}
+		uploads = append(uploads, apiIngestResult{Field: part.FormName(), File: part.FileName()})
+		files = append(files, data)
+	}
+	if len(uploads) == 0 {
+		return nil, http.StatusBadRequest, "no files in upload"
+	}
+
+	results := make([]apiIngestResult, 0, len(uploads))
+	for i, res := range uploads {
+		data := files[i]
+		// Uploads have no upstream id, so the content itself is the key.
+		sum := sha256.Sum256(data)
+		storeRes, err := a.storeToGallery(r.Context(), gallery.StoreInput{
+			Source:      "upload",
+			SourceKey:   "upload_" + hex.EncodeToString(sum[:]),
+			SourceURL:   sourceURL,
+			RawData:     data,
+			CollectedAt: time.Now().Unix(),
+			Title:       title,
+		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "api upload failed", "file", res.File, "err", err)
+			res.Error = err.Error()
+			results = append(results, res)
+			continue
+		}
+		res.Added = storeRes.Added
+		res.SkipReason = storeRes.SkipReason
+		res.ContentHash = storeRes.ContentHash
+		if storeRes.Added {
+			img := a.toAPIImage(storeRes.Image)
+			counts := storeRes.Counts
+			res.Image, res.Counts = &img, &counts
+		}
+		results = append(results, res)
+	}
+	return results, http.StatusOK, ""
+}
+
+func (a *App) apiIngestURLs(r *http.Request) ([]apiIngestResult, int, string) {
+	var body struct {
+		URLs []string `json:"urls"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, http.StatusBadRequest, "invalid JSON body"
+	}
+	links := extractSupportedLinks(body.URLs...)
+	if len(links) == 0 {
+		return nil, http.StatusBadRequest, "no supported links in urls"
+	}
+	if len(links) > apiIngestMaxLinks {
+		return nil, http.StatusBadRequest, fmt.Sprintf("at most %d links per request", apiIngestMaxLinks)
+	}
+
+	results := make([]apiIngestResult, 0, len(links))
+	for _, item := range links {
+		res := apiIngestResult{URL: item.URL}
+		out, err := a.ingestLink(r.Context(), item)
+		switch {
+		case err != nil:
+			res.Error = fmt.Sprintf("%s %s: %v", item.label(), item.ID, err)
+		case out != nil:
+			res.ID = out.ID
+			res.Title = out.Title
+			res.Summary = out.Summary
+			if s := out.Stats; s != nil {
+				res.Added = s.Downloaded > 0
+				res.SkipReason = s.SkipReason
+				res.Images = &apiIngestImages{Added: s.Downloaded, Skipped: s.Skipped, Failed: s.Failed}
+			}
+		}
+		results = append(results, res)
+	}
+	return results, http.StatusOK, ""
+}
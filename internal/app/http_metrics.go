@@ -0,0 +1,28 @@
+package app
+
+import (
+	"log/slog"
+	"net/http"
+
+	"tyr-blog-img/internal/metrics"
+)
+
+// RegisterMetricsHandler serves Prometheus metrics on GET /metrics unless
+// METRICS_ENABLED=false.
+func (a *App) RegisterMetricsHandler(mux *http.ServeMux) {
+	if a.Cfg != nil && !a.Cfg.MetricsEnabled {
+		slog.Info("metrics endpoint disabled (METRICS_ENABLED=false)")
+		return
+	}
+	promHandler := metrics.Handler()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		// Counts go through the public cache, so scrapes cost at most one
+		// D1 query per publicCountsTTL.
+		if meta, err := a.cachedMetadata(r.Context()); err != nil {
+			slog.Warn("metrics counts failed", "err", err)
+		} else {
+			metrics.SetGalleryCounts(meta.H, meta.V)
+		}
+		promHandler.ServeHTTP(w, r)
+	})
+}
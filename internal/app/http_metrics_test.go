@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	a, mux := newTestPublicApp(t, 3, 2)
+	a.Cfg.MetricsEnabled = true
+	a.RegisterMetricsHandler(mux)
+
+	// One failing target among successes keeps the run healthy; a run where
+	// everything failed does not.
+	a.runCrawler(context.Background(), "test_partial", func(run *crawlRun) {
+		run.record(nil)
+		run.record(errors.New("gone"))
+	})
+	a.runCrawler(context.Background(), "test_down", func(run *crawlRun) { run.record(errors.New("cookie expired")) })
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`tyr_gallery_images{orientation="h"} 3`,
+		`tyr_gallery_images{orientation="v"} 2`,
+		`tyr_crawler_runs_total{crawler="test_partial",result="success"} 1`,
+		`tyr_crawler_runs_total{crawler="test_down",result="failure"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	if strings.Contains(body, `tyr_crawler_last_success_timestamp_seconds{crawler="test_down"}`) {
+		t.Error("failed run recorded a last success")
+	}
+}
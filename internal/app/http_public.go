@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tyr-blog-img/internal/database"
+)
+
+// publicCountsTTL bounds how stale /counts.json, /random and /random.js may
+// be; it keeps blog traffic from turning into one D1 query per page view.
+const publicCountsTTL = 30 * time.Second
+
+type countsCache struct {
+	mu        sync.Mutex
+	meta      galleryMetadata
+	fetchedAt time.Time
+}
+
+// RegisterPublicHandlers serves the endpoints the fuwari gallery reads:
+// GET /counts.json, GET /random?o=h|v and GET /random.js.
+func (a *App) RegisterPublicHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/counts.json", a.handleCountsJSON)
+	mux.HandleFunc("/random", a.handleRandomImage)
+	mux.HandleFunc("/random.js", a.handleRandomScript)
+}
+
+func (a *App) handleCountsJSON(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	meta, err := a.cachedMetadata(r.Context())
+	if err != nil {
+		slog.Error("counts.json failed", "err", err)
+		http.Error(w, "counts unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	_ = json.NewEncoder(w).Encode(meta)
+}
+
+func (a *App) handleRandomImage(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	base := a.imageBaseURL()
+	if base == "" {
+		http.Error(w, "IMAGE_DOMAIN not configured", http.StatusServiceUnavailable)
+		return
+	}
+	meta, err := a.cachedMetadata(r.Context())
+	if err != nil {
+		slog.Error("random image failed", "err", err)
+		http.Error(w, "counts unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	o := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("o")))
+	switch o {
+	case "h", "v":
+	case "":
+		o = pickOrientation(meta.GalleryCounts)
+	default:
+		http.Error(w, "o must be h or v", http.StatusBadRequest)
+		return
+	}
+	n := meta.H
+	if o == "v" {
+		n = meta.V
+	}
+	if n < 1 {
+		http.Error(w, "no images", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.Redirect(w, r, fmt.Sprintf("%s/ri/%s/%d.webp", base, o, rand.Int64N(n)+1), http.StatusFound)
+}
+
+func (a *App) handleRandomScript(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	meta, err := a.cachedMetadata(r.Context())
+	if err != nil {
+		slog.Error("random.js failed", "err", err)
+		http.Error(w, "counts unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	script, err := buildRandomScript(meta.GalleryCounts, a.imageBaseURL())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	_, _ = w.Write(script)
+}
+
+func (a *App) cachedMetadata(ctx context.Context) (galleryMetadata, error) {
+	a.counts.mu.Lock()
+	defer a.counts.mu.Unlock()
+	if !a.counts.fetchedAt.IsZero() && time.Since(a.counts.fetchedAt) < publicCountsTTL {
+		return a.counts.meta, nil
+	}
+	meta, err := a.currentGalleryMetadata(ctx)
+	if err != nil {
+		return galleryMetadata{}, err
+	}
+	a.counts.meta = meta
+	a.counts.fetchedAt = time.Now()
+	return meta, nil
+}
+
+// invalidateCountsCache makes the next public request re-read counts, e.g.
+// right after a metadata publish.
+func (a *App) invalidateCountsCache() {
+	a.counts.mu.Lock()
+	a.counts.fetchedAt = time.Time{}
+	a.counts.mu.Unlock()
+}
+
+func (a *App) imageBaseURL() string {
+	if a.Cfg == nil {
+		return ""
+	}
+	domain := strings.TrimRight(strings.TrimSpace(a.Cfg.ImageDomain), "/")
+	if domain == "" {
+		return ""
+	}
+	if !strings.HasPrefix(domain, "http://") && !strings.HasPrefix(domain, "https://") {
+		domain = "https://" + domain
+	}
+	return domain
+}
+
+// buildRandomScript renders a self-contained random.js. It keeps the
+// `var counts = {...};` line that patchRandomScriptCounts understands, so the
+// output can also be uploaded to R2 as-is.
+func buildRandomScript(counts database.GalleryCounts, base string) ([]byte, error) {
+	countsJSON, err := json.Marshal(counts)
+	if err != nil {
+		return nil, err
+	}
+	baseJSON, err := json.Marshal(base + "/ri/")
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString("var counts = " + string(countsJSON) + ";\n")
+	b.WriteString("(function () {\n")
+	b.WriteString("  var base = " + string(baseJSON) + ";\n")
+	b.WriteString(`  function pick(o) {
+    if (o !== "h" && o !== "v") {
+      var total = counts.h + counts.v;
+      o = total > 0 && Math.random() * total < counts.v ? "v" : "h";
+    }
+    var n = counts[o] || 0;
+    if (n < 1) return "";
+    return base + o + "/" + (Math.floor(Math.random() * n) + 1) + ".webp";
+  }
+  window.randomImageCounts = counts;
+  window.randomImageURL = pick;
+})();
+`)
+	return []byte(b.String()), nil
+}
+
+func pickOrientation(counts database.GalleryCounts) string {
+	total := counts.H + counts.V
+	if total > 0 && rand.Int64N(total) < counts.V {
+		return "v"
+	}
+	return "h"
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tyr-blog-img/internal/config"
+	"tyr-blog-img/internal/database"
+)
+
+func newTestPublicApp(t *testing.T, h, v int) (*App, *http.ServeMux) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.NewSQLite(filepath.Join(t.TempDir(), "gallery.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.EnsureSchema(ctx); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+	insert := func(o string, n int) {
+		for seq := 1; seq <= n; seq++ {
+			err := db.InsertGalleryImage(ctx, database.GalleryImage{
+				SourceKey:   fmt.Sprintf("%s_%d", o, seq),
+				SHA256:      fmt.Sprintf("%s%d", o, seq),
+				Orientation: o,
+				Seq:         int64(seq),
+				R2Key:       fmt.Sprintf("ri/%s/%d.webp", o, seq),
+			})
+			if err != nil {
+				t.Fatalf("InsertGalleryImage: %v", err)
+			}
+		}
+	}
+	insert("h", h)
+	insert("v", v)
+
+	a := &App{Cfg: &config.Config{ImageDomain: "img.example.com/"}, DB: db}
+	mux := http.NewServeMux()
+	a.RegisterPublicHandlers(mux)
+	return a, mux
+}
+
+func TestPublicCountsJSON(t *testing.T) {
+	_, mux := newTestPublicApp(t, 3, 2)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/counts.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"h":3,"v":2}` {
+		t.Fatalf("body = %s", got)
+	}
+}
+
+func TestPublicRandomRedirect(t *testing.T) {
+	_, mux := newTestPublicApp(t, 0, 2)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/random?o=v", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", rec.Code)
+	}
+	loc := rec.Header().Get("Location")
+	if loc != "https://img.example.com/ri/v/1.webp" && loc != "https://img.example.com/ri/v/2.webp" {
+		t.Fatalf("Location = %q", loc)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/random?o=h", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("empty orientation status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/random?o=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad orientation status = %d, want 400", rec.Code)
+	}
+}
+
+func TestPublicRandomScriptKeepsPatchableCounts(t *testing.T) {
+	_, mux := newTestPublicApp(t, 1, 1)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/random.js", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `var counts = {"h":1,"v":1};`) {
+		t.Fatalf("random.js missing counts line:\n%s", body)
+	}
+	if !strings.Contains(body, `"https://img.example.com/ri/"`) {
+		t.Fatalf("random.js missing image base:\n%s", body)
+	}
+}
@@ -0,0 +1,252 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tyr-blog-img/internal/database"
+	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/logging"
+)
+
+// Job kinds tell the worker how to fetch a job's URL.
+const (
+	ingestJobKindHTTP   = "http"
+	ingestJobKindPixiv  = "pixiv"        // pximg URL, fetched with the Pixiv cookie
+	ingestJobKindUgoira = "pixiv_ugoira" // artwork page; the frames are re-assembled
+)
+
+const (
+	ingestJobBaseBackoff = time.Minute
+	ingestJobMaxBackoff  = 6 * time.Hour
+	// ingestJobLease is how long a claimed job may run before it becomes due
+	// again, which covers workers killed mid-download.
+	ingestJobLease = 15 * time.Minute
+	// ingestJobPausedDelay is how long a job of a paused crawler waits
+	// before it is looked at again.
+	ingestJobPausedDelay     = 5 * time.Minute
+	ingestJobDownloadTimeout = 90 * time.Second
+)
+
+// ingestJobPayload is the part of the store input a retry cannot rebuild
+// from the job row itself.
+type ingestJobPayload struct {
+	SourceURL    string   `json:"source_url,omitempty"`
+	SourcePostID string   `json:"source_post_id,omitempty"`
+	PublishedAt  int64    `json:"published_at,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	AuthorName   string   `json:"author_name,omitempty"`
+	AuthorID     string   `json:"author_id,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// FallbackURLs are tried in order after the job URL, like the booru
+	// sample and preview files.
+	FallbackURLs []string `json:"fallback_urls,omitempty"`
+}
+
+// ingestRetry says how to fetch an item again: URLs[0] is the job URL and
+// the rest are fallbacks.
+type ingestRetry struct {
+	Kind    string
+	URLs    []string
+	Referer string
+}
+
+// enqueueIngestRetry hands an item whose download or store failed to the
+// job queue. The inline try counts as the first attempt. Items cut short by
+// /pause or shutdown are not queued: the crawler's mark stays below them, so
+// its next run picks them up, and a paused crawler should stay quiet.
+func (a *App) enqueueIngestRetry(ctx context.Context, in gallery.StoreInput, retry ingestRetry, cause error) {
+	if len(retry.URLs) == 0 || cause == nil || errors.Is(cause, context.Canceled) {
+		return
+	}
+	payload, err := json.Marshal(ingestJobPayload{
+		SourceURL:    in.SourceURL,
+		SourcePostID: in.SourcePostID,
+		PublishedAt:  in.PublishedAt,
+		Title:        in.Title,
+		AuthorName:   in.AuthorName,
+		AuthorID:     in.AuthorID,
+		Tags:         in.Tags,
+		FallbackURLs: retry.URLs[1:],
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "ingest job payload failed", "source_key", in.SourceKey, "err", err)
+		return
+	}
+	next := time.Now().Add(ingestJobBackoff(1))
+	// The run may be cancelled right after a real failure; the job must
+	// still be recorded.
+	added, err := a.DB.EnqueueIngestJob(context.WithoutCancel(ctx), database.IngestJob{
+		Source:    in.Source,
+		SourceKey: in.SourceKey,
+		URL:       retry.URLs[0],
+		Kind:      retry.Kind,
+		Referer:   retry.Referer,
+		Payload:   string(payload),
+		Attempts:  1,
+		NextRunAt: next.Unix(),
+		LastError: cause.Error(),
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "ingest job enqueue failed", "source_key", in.SourceKey, "err", err)
+		return
+	}
+	if added {
+		slog.InfoContext(ctx, "ingest job queued", "source_key", in.SourceKey, "retry_at", next.Format(time.RFC3339))
+	}
+}
+
+// ingestJobBackoff is the wait after the given number of failed attempts:
+// one minute doubling up to six hours.
+func ingestJobBackoff(attempts int) time.Duration {
+	d := ingestJobBaseBackoff
+	for i := 1; i < attempts && d < ingestJobMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, ingestJobMaxBackoff)
+}
+
+// StartIngestWorkers runs INGEST_WORKERS goroutines that retry due ingest
+// jobs, each polling every INGEST_JOB_POLL_SECONDS.
+func (a *App) StartIngestWorkers(ctx context.Context) {
+	if a.Cfg == nil || a.Cfg.IngestWorkers <= 0 {
+		slog.Info("ingest workers disabled")
+		return
+	}
+	poll := time.Duration(maxInt(a.Cfg.IngestJobPollSeconds, 30)) * time.Second
+	slog.Info("ingest workers started", "workers", a.Cfg.IngestWorkers, "poll", poll)
+	for i := 0; i < a.Cfg.IngestWorkers; i++ {
+		go func() {
+			ticker := time.NewTicker(poll)
+			defer ticker.Stop()
+			for {
+				a.runDueIngestJobs(ctx)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// runDueIngestJobs claims and runs due jobs one at a time until none is left.
+// Jobs whose source has a paused crawler loop (see /pause) are handed back
+// untried, without using up an attempt, and looked at again later.
+func (a *App) runDueIngestJobs(ctx context.Context) {
+	paused := make(map[string]bool)
+	for _, loop := range crawlerLoopNames {
+		paused[loop] = a.crawlerPaused(ctx, loop)
+	}
+	for ctx.Err() == nil {
+		now := time.Now()
+		jobs, err := a.DB.ClaimIngestJobs(ctx, now.Unix(), now.Add(ingestJobLease).Unix(), 1)
+		if err != nil {
+			slog.Warn("ingest job claim failed", "err", err)
+			return
+		}
+		if len(jobs) == 0 {
+			return
+		}
+		if job := jobs[0]; paused[job.Source] {
+			if err := a.DB.ReleaseIngestJob(ctx, job.ID, now.Add(ingestJobPausedDelay).Unix()); err != nil {
+				slog.Warn("ingest job release failed", "job", job.ID, "err", err)
+			}
+			continue
+		}
+		a.runIngestJob(ctx, jobs[0])
+	}
+}
+
+// runIngestJob retries one claimed job. Successes are removed; failures are
+// rescheduled with backoff, or dead-lettered after INGEST_JOB_MAX_ATTEMPTS.
+func (a *App) runIngestJob(ctx context.Context, job database.IngestJob) {
+	ctx = logging.WithIngest(ctx, job.Source, job.SourceKey)
+	err := a.retryIngestJob(ctx, job)
+	if err == nil {
+		if err := a.DB.CompleteIngestJob(ctx, job.ID); err != nil {
+			slog.WarnContext(ctx, "ingest job complete failed", "job", job.ID, "err", err)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// Shutting down: leave the lease to expire so the job runs again.
+		return
+	}
+	dead := job.Attempts >= maxInt(a.Cfg.IngestJobMaxAttempts, 6)
+	next := time.Now().Add(ingestJobBackoff(job.Attempts))
+	if ferr := a.DB.FailIngestJob(ctx, job.ID, err.Error(), next.Unix(), dead); ferr != nil {
+		slog.WarnContext(ctx, "ingest job update failed", "job", job.ID, "err", ferr)
+	}
+	if dead {
+		slog.WarnContext(ctx, "ingest job dead", "job", job.ID, "attempts", job.Attempts, "err", err)
+	} else {
+		slog.InfoContext(ctx, "ingest job failed", "job", job.ID, "attempts", job.Attempts, "retry_at", next.Format(time.RFC3339), "err", err)
+	}
+}
+
+func (a *App) retryIngestJob(ctx context.Context, job database.IngestJob) error {
+	var p ingestJobPayload
+	if job.Payload != "" {
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+	}
+	// The item may have been stored or blocked since the job was queued.
+	if blocked, err := a.DB.IsBlocked(ctx, job.SourceKey); err == nil && blocked {
+		return nil
+	}
+	if exists, _ := a.DB.ExistsGallerySourceKey(ctx, job.SourceKey); exists {
+		return nil
+	}
+	data, err := a.downloadIngestJob(ctx, job, p)
+	if err != nil {
+		return err
+	}
+	_, err = a.storeToGallery(ctx, gallery.StoreInput{
+		Source:       job.Source,
+		SourceKey:    job.SourceKey,
+		SourceURL:    p.SourceURL,
+		SourcePostID: p.SourcePostID,
+		RawData:      data,
+		PublishedAt:  p.PublishedAt,
+		CollectedAt:  time.Now().Unix(),
+		Title:        p.Title,
+		AuthorName:   p.AuthorName,
+		AuthorID:     p.AuthorID,
+		Tags:         p.Tags,
+	})
+	return err
+}
+
+func (a *App) downloadIngestJob(ctx context.Context, job database.IngestJob, p ingestJobPayload) ([]byte, error) {
+	switch job.Kind {
+	case ingestJobKindPixiv, ingestJobKindUgoira:
+		if a.Pixiv == nil {
+			return nil, fmt.Errorf("pixiv client not configured")
+		}
+		if job.Kind == ingestJobKindUgoira {
+			return a.downloadPixivUgoira(ctx, p.SourcePostID)
+		}
+		return a.Pixiv.Download(job.URL)
+	case ingestJobKindHTTP, "":
+		var (
+			data []byte
+			err  error
+		)
+		for _, u := range append([]string{job.URL}, p.FallbackURLs...) {
+			data, err = downloadWithHeadersTimeout(ctx, u, job.Referer, ingestJobDownloadTimeout)
+			if err == nil {
+				return data, nil
+			}
+		}
+		return nil, err
+	default:
+		return nil, fmt.Errorf("unknown ingest job kind %q", job.Kind)
+	}
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tyr-blog-img/internal/config"
+	"tyr-blog-img/internal/database"
+	"tyr-blog-img/internal/gallery"
+)
+
+func TestIngestJobBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tc := range cases {
+		if got := ingestJobBackoff(tc.attempts); got != tc.want {
+			t.Fatalf("ingestJobBackoff(%d) = %s, want %s", tc.attempts, got, tc.want)
+		}
+	}
+}
+
+// fakeJobStore keeps ingest jobs in memory and claims every pending job
+// regardless of its next run time, so one runDueIngestJobs call drives a
+// failing job through all its attempts. Other Store methods panic.
+type fakeJobStore struct {
+	database.Store
+
+	mu        sync.Mutex
+	jobs      map[int64]*database.IngestJob
+	blocked   map[string]bool
+	stored    map[string]bool
+	state     map[string]string
+	completed []int64
+}
+
+func (f *fakeJobStore) EnqueueIngestJob(_ context.Context, job database.IngestJob) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.jobs == nil {
+		f.jobs = make(map[int64]*database.IngestJob)
+	}
+	job.ID = int64(len(f.jobs) + 1)
+	job.Status = database.IngestJobPending
+	f.jobs[job.ID] = &job
+	return true, nil
+}
+
+func (f *fakeJobStore) GetCrawlerState(_ context.Context, key string) (string, bool, error) {
+	v, ok := f.state[key]
+	return v, ok, nil
+}
+
+func (f *fakeJobStore) ReleaseIngestJob(_ context.Context, id, nextRunAt int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job := f.jobs[id]
+	job.Attempts--
+	job.NextRunAt = nextRunAt
+	// Park it so the fake's claim, which ignores next_run_at, moves on.
+	job.Status = "released"
+	return nil
+}
+
+func (f *fakeJobStore) ClaimIngestJobs(_ context.Context, _, leaseUntil int64, _ int) ([]database.IngestJob, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]int64, 0, len(f.jobs))
+	for id := range f.jobs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if job := f.jobs[id]; job.Status == database.IngestJobPending {
+			job.Attempts++
+			job.NextRunAt = leaseUntil
+			return []database.IngestJob{*job}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeJobStore) CompleteIngestJob(_ context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.jobs, id)
+	f.completed = append(f.completed, id)
+	return nil
+}
+
+func (f *fakeJobStore) FailIngestJob(_ context.Context, id int64, lastError string, nextRunAt int64, dead bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job := f.jobs[id]
+	job.LastError, job.NextRunAt = lastError, nextRunAt
+	if dead {
+		job.Status = database.IngestJobDead
+	}
+	return nil
+}
+
+func (f *fakeJobStore) IsBlocked(_ context.Context, key string) (bool, error) {
+	return f.blocked[key], nil
+}
+
+func (f *fakeJobStore) ExistsGallerySourceKey(_ context.Context, key string) (bool, error) {
+	return f.stored[key], nil
+}
+
+func TestIngestWorkerRetriesUntilDeadAndSkipsHandledItems(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "gone", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	job := func(id int64, key string) *database.IngestJob {
+		return &database.IngestJob{ID: id, Source: "yande", SourceKey: key, URL: srv.URL + "/" + key, Kind: ingestJobKindHTTP, Status: database.IngestJobPending, Attempts: 1}
+	}
+	db := &fakeJobStore{
+		jobs: map[int64]*database.IngestJob{
+			1: job(1, "yande_1"),
+			2: job(2, "yande_2"),
+			3: job(3, "yande_3"),
+		},
+		blocked: map[string]bool{"yande_2": true},
+		stored:  map[string]bool{"yande_3": true},
+	}
+	a := &App{Cfg: &config.Config{IngestJobMaxAttempts: 6}, DB: db}
+
+	before := time.Now()
+	a.runDueIngestJobs(context.Background())
+
+	// The blocked and the already stored items complete without a download.
+	if len(db.completed) != 2 || db.completed[0] != 2 || db.completed[1] != 3 {
+		t.Fatalf("completed = %v, want [2 3]", db.completed)
+	}
+	// The failing one is tried on attempts 2..6 and then dead-lettered.
+	dead := db.jobs[1]
+	if dead == nil || dead.Status != database.IngestJobDead || dead.Attempts != 6 {
+		t.Fatalf("job 1 = %+v, want dead after 6 attempts", dead)
+	}
+	if n := hits.Load(); n != 5 {
+		t.Fatalf("downloads = %d, want 5", n)
+	}
+	if dead.LastError != "download status 502" {
+		t.Fatalf("last error = %q", dead.LastError)
+	}
+	if earliest := before.Add(ingestJobBackoff(6)).Unix(); dead.NextRunAt < earliest {
+		t.Fatalf("next run = %d, want backoff of attempt 6 (>= %d)", dead.NextRunAt, earliest)
+	}
+}
+
+func TestEnqueueIngestRetrySkipsCancelled(t *testing.T) {
+	db := &fakeJobStore{}
+	a := &App{Cfg: &config.Config{}, DB: db}
+	in := gallery.StoreInput{Source: "yande", SourceKey: "yande_1"}
+	retry := ingestRetry{Kind: ingestJobKindHTTP, URLs: []string{"https://files.yande.re/1.jpg"}}
+	a.enqueueIngestRetry(context.Background(), in, retry, fmt.Errorf("download: %w", context.Canceled))
+	if len(db.jobs) != 0 {
+		t.Fatalf("cancelled download queued: %+v", db.jobs[1])
+	}
+	a.enqueueIngestRetry(context.Background(), in, retry, fmt.Errorf("download status 502"))
+	if len(db.jobs) != 1 || db.jobs[1].Attempts != 1 {
+		t.Fatalf("jobs = %+v, want the failed download queued once", db.jobs)
+	}
+}
+
+func TestIngestWorkerSkipsPausedCrawlerJobs(t *testing.T) {
+	db := &fakeJobStore{
+		jobs: map[int64]*database.IngestJob{
+			1: {ID: 1, Source: "danbooru", SourceKey: "danbooru_1", URL: "http://127.0.0.1:1/x", Status: database.IngestJobPending, Attempts: 2},
+			2: {ID: 2, Source: "yande", SourceKey: "yande_2", URL: "http://127.0.0.1:1/x", Status: database.IngestJobPending, Attempts: 1},
+		},
+		stored: map[string]bool{"yande_2": true},
+		state:  map[string]string{crawlerPausedPrefix + "danbooru": "1"},
+	}
+	a := &App{Cfg: &config.Config{IngestJobMaxAttempts: 6}, DB: db}
+
+	before := time.Now()
+	a.runDueIngestJobs(context.Background())
+
+	if len(db.completed) != 1 || db.completed[0] != 2 {
+		t.Fatalf("completed = %v, want only the yande job", db.completed)
+	}
+	held := db.jobs[1]
+	if held.Status != "released" || held.Attempts != 2 || held.NextRunAt < before.Add(ingestJobPausedDelay).Unix() {
+		t.Fatalf("paused job = %+v, want released with its attempts unchanged", held)
+	}
+}
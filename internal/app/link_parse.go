@@ -2,10 +2,13 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	neturl "net/url"
 	"regexp"
 	"strings"
+
+	"tyr-blog-img/internal/logging"
 )
 
 const maxTGLinksPerMessage = 3
@@ -13,9 +16,10 @@ const maxTGLinksPerMessage = 3
 var (
 	urlPattern         = regexp.MustCompile(`https?://[^\s]+`)
 	pixivIDPattern     = regexp.MustCompile(`^\d+$`)
-	yandeIDPattern     = regexp.MustCompile(`^\d+$`)
+	booruIDPattern     = regexp.MustCompile(`^\d+$`)
 	twitterIDPattern   = regexp.MustCompile(`^\d+$`)
 	pinterestIDPattern = regexp.MustCompile(`^\d+$`)
+	danbooruIDPattern  = regexp.MustCompile(`^\d+$`)
 	punctuationTrim    = ".,;:!?)]}>'\"\uFF0C\u3002\uFF01\uFF1F\u3001\uFF09\u3011\u300B"
 )
 
@@ -23,15 +27,18 @@ type linkType string
 
 const (
 	linkPixiv     linkType = "pixiv"
-	linkYande     linkType = "yande"
+	linkBooru     linkType = "booru"
 	linkTwitter   linkType = "twitter"
 	linkPinterest linkType = "pinterest"
+	linkDanbooru  linkType = "danbooru"
 )
 
 type supportedLink struct {
 	Type linkType
 	ID   string
 	URL  string
+	// Host picks the board for linkBooru, see booruSites.
+	Host string
 }
 
 type ingestStats struct {
@@ -40,6 +47,9 @@ type ingestStats struct {
 	Downloaded int
 	Skipped    int
 	Failed     int
+	// SkipReason is set when the whole work was filtered out before
+	// downloading.
+	SkipReason string
 }
 
 func extractSupportedLinks(parts ...string) []supportedLink {
@@ -79,14 +89,33 @@ func extractSupportedLinks(parts ...string) []supportedLink {
 			}
 		}
 
-		if host == "yande.re" && len(segments) >= 3 && segments[0] == "post" && segments[1] == "show" && yandeIDPattern.MatchString(segments[2]) {
-			id := segments[2]
-			key := string(linkYande) + ":" + id
-			if _, ok := seen[key]; ok {
-				continue
+		if site, ok := booruSiteForHost(host); ok {
+			if id, ok := parseBooruPostID(site, segments, u.Query()); ok {
+				key := string(linkBooru) + ":" + site.SourcePrefix + ":" + id
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+				out = append(out, supportedLink{Type: linkBooru, ID: id, URL: clean, Host: host})
+			}
+		}
+
+		if host == "danbooru.donmai.us" && len(segments) >= 2 && danbooruIDPattern.MatchString(segments[1]) {
+			var id string
+			switch segments[0] {
+			case "posts":
+				id = segments[1]
+			case "pools":
+				id = danbooruPoolLinkIDPrefix + segments[1]
+			}
+			if id != "" {
+				key := string(linkDanbooru) + ":" + id
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+				out = append(out, supportedLink{Type: linkDanbooru, ID: id, URL: clean})
 			}
-			seen[key] = struct{}{}
-			out = append(out, supportedLink{Type: linkYande, ID: id, URL: clean})
 		}
 
 		if isTwitterHost(host) {
@@ -178,6 +207,40 @@ func normalizeTwitterUsername(username string) string {
 	return username
 }
 
+var errUnsupportedLink = errors.New("unsupported link")
+
+// ingestLink routes one parsed link to its source ingestor.
+func (a *App) ingestLink(ctx context.Context, item supportedLink) (*TGIngestResult, error) {
+	source := string(item.Type)
+	if item.Host != "" {
+		source = item.Host
+	}
+	ctx = logging.WithIngest(ctx, source, item.ID)
+	switch item.Type {
+	case linkPixiv:
+		return a.ingestPixivFromLink(ctx, item)
+	case linkBooru:
+		return a.ingestBooruFromLink(ctx, item)
+	case linkTwitter:
+		return a.ingestTwitterFromLink(ctx, item)
+	case linkPinterest:
+		return a.ingestPinterestFromLink(ctx, item)
+	case linkDanbooru:
+		return a.ingestDanbooruFromLink(ctx, item)
+	default:
+		return nil, errUnsupportedLink
+	}
+}
+
+// label names the link's site in summaries: the host for booru links,
+// otherwise the link type.
+func (item supportedLink) label() string {
+	if item.Host != "" {
+		return item.Host
+	}
+	return strings.ToUpper(string(item.Type))
+}
+
 func (a *App) handleTGLinks(ctx context.Context, links []supportedLink) (*TGIngestResult, error) {
 	if len(links) > maxTGLinksPerMessage {
 		links = links[:maxTGLinksPerMessage]
@@ -185,24 +248,12 @@ func (a *App) handleTGLinks(ctx context.Context, links []supportedLink) (*TGInge
 	var summaries []string
 	var first *TGIngestResult
 	for _, item := range links {
-		var (
-			res *TGIngestResult
-			err error
-		)
-		switch item.Type {
-		case linkPixiv:
-			res, err = a.ingestPixivFromLink(ctx, item)
-		case linkYande:
-			res, err = a.ingestYandeFromLink(ctx, item)
-		case linkTwitter:
-			res, err = a.ingestTwitterFromLink(ctx, item)
-		case linkPinterest:
-			res, err = a.ingestPinterestFromLink(ctx, item)
-		default:
+		res, err := a.ingestLink(ctx, item)
+		if errors.Is(err, errUnsupportedLink) {
 			continue
 		}
 		if err != nil {
-			summaries = append(summaries, fmt.Sprintf("%s %s 失败：%v", strings.ToUpper(string(item.Type)), item.ID, err))
+			summaries = append(summaries, fmt.Sprintf("%s %s 失败：%v", item.label(), item.ID, err))
 			continue
 		}
 		if res != nil {
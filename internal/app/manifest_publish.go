@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"tyr-blog-img/internal/database"
+)
+
+const (
+	// manifestPageSize is the seq span of one manifest page: page p holds
+	// seqs (p-1)*size+1 .. p*size, so appending images only rewrites the
+	// last page.
+	manifestPageSize     = 100
+	manifestIndexKey     = "manifest/index.json"
+	manifestCacheControl = "public, max-age=60"
+)
+
+// objectETagStore is implemented by stores that can report the ETag of an
+// existing object, letting unchanged manifest pages be skipped.
+type objectETagStore interface {
+	ObjectETag(ctx context.Context, key string) (string, bool, error)
+}
+
+type manifestEntry struct {
+	Seq       int64  `json:"seq"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Bytes     int64  `json:"bytes"`
+	Source    string `json:"source"`
+	SourceURL string `json:"source_url,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Author    string `json:"author,omitempty"`
+	AuthorID  string `json:"author_id,omitempty"`
+}
+
+type manifestPage struct {
+	Orientation string          `json:"orientation"`
+	Page        int             `json:"page"`
+	Items       []manifestEntry `json:"items"`
+}
+
+type manifestOrientation struct {
+	// LastSeq is the highest seq; seqs without an entry predate D1.
+	LastSeq int64 `json:"last_seq"`
+	Count   int   `json:"count"`
+	Pages   int   `json:"pages"`
+}
+
+type manifestIndex struct {
+	PageSize     int                            `json:"page_size"`
+	Orientations map[string]manifestOrientation `json:"orientations"`
+}
+
+type manifestResult struct {
+	Written   int
+	Unchanged int
+	Removed   int
+}
+
+func manifestPageKey(orientation string, page int) string {
+	return fmt.Sprintf("manifest/%s/%d.json", orientation, page)
+}
+
+// publishManifest writes manifest/{o}/{page}.json for every page of both
+// orientations plus manifest/index.json, skipping objects whose ETag
+// already matches. Pages past the end of the previous index are deleted.
+func (a *App) publishManifest(ctx context.Context, store metadataPublisherStore, counts database.GalleryCounts) (manifestResult, error) {
+	var res manifestResult
+	var previous manifestIndex
+	if raw, _, err := store.GetObject(ctx, manifestIndexKey); err == nil {
+		_ = json.Unmarshal(raw, &previous)
+	}
+
+	index := manifestIndex{PageSize: manifestPageSize, Orientations: make(map[string]manifestOrientation, 2)}
+	for _, o := range []string{"h", "v"} {
+		lastSeq := counts.H
+		if o == "v" {
+			lastSeq = counts.V
+		}
+		images, err := a.DB.ListActiveGalleryImages(ctx, o)
+		if err != nil {
+			return res, fmt.Errorf("list %s images: %w", o, err)
+		}
+		pages := int((lastSeq + manifestPageSize - 1) / manifestPageSize)
+		byPage := make([][]manifestEntry, pages)
+		count := 0
+		for _, img := range images {
+			p := int((img.Seq - 1) / manifestPageSize)
+			if img.Seq < 1 || p >= pages {
+				continue
+			}
+			byPage[p] = append(byPage[p], manifestEntry{
+				Seq:       img.Seq,
+				Width:     img.Width,
+				Height:    img.Height,
+				Bytes:     img.Bytes,
+				Source:    img.Source,
+				SourceURL: img.SourceURL,
+				Title:     img.Title,
+				Author:    img.AuthorName,
+				AuthorID:  img.AuthorID,
+			})
+			count++
+		}
+
+		for i, items := range byPage {
+			if items == nil {
+				items = []manifestEntry{}
+			}
+			body, err := json.Marshal(manifestPage{Orientation: o, Page: i + 1, Items: items})
+			if err != nil {
+				return res, err
+			}
+			written, err := putObjectIfChanged(ctx, store, manifestPageKey(o, i+1), body, manifestCacheControl)
+			if err != nil {
+				return res, err
+			}
+			if written {
+				res.Written++
+			} else {
+				res.Unchanged++
+			}
+		}
+
+		for p := pages + 1; p <= previous.Orientations[o].Pages; p++ {
+			if err := a.Gallery.Store.DeleteObject(ctx, manifestPageKey(o, p)); err != nil {
+				slog.Warn("manifest stale page delete failed", "key", manifestPageKey(o, p), "err", err)
+				continue
+			}
+			res.Removed++
+		}
+		index.Orientations[o] = manifestOrientation{LastSeq: lastSeq, Count: count, Pages: pages}
+	}
+
+	body, err := json.Marshal(index)
+	if err != nil {
+		return res, err
+	}
+	written, err := putObjectIfChanged(ctx, store, manifestIndexKey, body, "public, max-age=30")
+	if err != nil {
+		return res, err
+	}
+	if written {
+		res.Written++
+	} else {
+		res.Unchanged++
+	}
+	return res, nil
+}
+
+// putObjectIfChanged uploads data unless the store reports an ETag equal to
+// its MD5. Stores without ETag support always get the upload.
+func putObjectIfChanged(ctx context.Context, store metadataPublisherStore, key string, data []byte, cacheControl string) (bool, error) {
+	if es, ok := store.(objectETagStore); ok {
+		sum := md5.Sum(data)
+		etag, found, err := es.ObjectETag(ctx, key)
+		if err == nil && found && strings.Trim(etag, `"`) == hex.EncodeToString(sum[:]) {
+			return false, nil
+		}
+	}
+	if err := store.PutObjectWithCacheControl(ctx, key, data, "application/json; charset=utf-8", cacheControl); err != nil {
+		return false, fmt.Errorf("upload %s: %w", key, err)
+	}
+	return true, nil
+}
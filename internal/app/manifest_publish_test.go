@@ -0,0 +1,53 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"tyr-blog-img/internal/database"
+	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/storage"
+)
+
+func TestPublishManifestSkipsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestPublicApp(t, 3, 0)
+	fs, err := storage.NewFSClient(storage.FSConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFSClient: %v", err)
+	}
+	a.Gallery = &gallery.Service{DB: a.DB, Store: fs}
+
+	res, err := a.publishManifest(ctx, fs, database.GalleryCounts{H: 3})
+	if err != nil {
+		t.Fatalf("publishManifest: %v", err)
+	}
+	if res.Written != 2 || res.Unchanged != 0 {
+		t.Fatalf("first publish = %+v, want 2 written (h page + index)", res)
+	}
+	raw, _, err := fs.GetObject(ctx, "manifest/h/1.json")
+	if err != nil {
+		t.Fatalf("GetObject page: %v", err)
+	}
+	var page manifestPage
+	if err := json.Unmarshal(raw, &page); err != nil || len(page.Items) != 3 || page.Items[2].Seq != 3 {
+		t.Fatalf("page = %s (%v)", raw, err)
+	}
+
+	if res, _ = a.publishManifest(ctx, fs, database.GalleryCounts{H: 3}); res.Written != 0 || res.Unchanged != 2 {
+		t.Fatalf("second publish = %+v, want everything unchanged", res)
+	}
+
+	// Seqs without D1 rows (legacy uploads) still get empty pages; shrinking
+	// drops them again.
+	if res, _ = a.publishManifest(ctx, fs, database.GalleryCounts{H: 150}); res.Written != 2 || res.Unchanged != 1 {
+		t.Fatalf("grown publish = %+v, want page 2 and index written", res)
+	}
+	if res, _ = a.publishManifest(ctx, fs, database.GalleryCounts{H: 3}); res.Removed != 1 {
+		t.Fatalf("shrunk publish = %+v, want 1 page removed", res)
+	}
+	if _, ok, _ := fs.ObjectETag(ctx, "manifest/h/2.json"); ok {
+		t.Fatal("stale page 2 still exists")
+	}
+}
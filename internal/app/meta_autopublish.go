@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"tyr-blog-img/internal/gallery"
+)
+
+const metadataAutoPublishTimeout = 2 * time.Minute
+
+// metadataAutoPublisher coalesces ingest bursts into one metadata publish.
+// It is a fixed-delay throttle rather than a debounce: the first successful
+// store arms a timer and further stores before it fires ride along without
+// pushing it back, so a long crawler run still publishes every delay
+// instead of only once it goes quiet.
+type metadataAutoPublisher struct {
+	mu    sync.Mutex
+	timer *time.Timer
+	// delay overrides Cfg.MetadataPublishDelay when set.
+	delay time.Duration
+}
+
+// storeToGallery is the single entry point every ingestor uses, so
+// post-store side effects only live here.
+func (a *App) storeToGallery(ctx context.Context, in gallery.StoreInput) (gallery.StoreResult, error) {
+	res, err := a.Gallery.StoreToGallery(ctx, in)
+	if err == nil && res.Added {
+		a.scheduleMetadataPublish()
+	}
+	return res, err
+}
+
+func (a *App) scheduleMetadataPublish() {
+	if a.Cfg == nil || !a.Cfg.MetadataAutoPublish {
+		return
+	}
+	delay := time.Duration(maxInt(a.Cfg.MetadataPublishDelay, 60)) * time.Second
+
+	a.autoPublish.mu.Lock()
+	defer a.autoPublish.mu.Unlock()
+	if a.autoPublish.timer != nil {
+		return
+	}
+	if a.autoPublish.delay > 0 {
+		delay = a.autoPublish.delay
+	}
+	a.autoPublish.timer = time.AfterFunc(delay, a.runScheduledMetadataPublish)
+}
+
+func (a *App) runScheduledMetadataPublish() {
+	a.autoPublish.mu.Lock()
+	a.autoPublish.timer = nil
+	a.autoPublish.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), metadataAutoPublishTimeout)
+	defer cancel()
+
+	summary, err := a.publishMetadata(ctx)
+	if err != nil {
+		slog.Error("metadata auto publish failed", "err", err)
+		a.notifyAdmin(ctx, fmt.Sprintf("自动更新 metadata 失败：%v\n可发送 /updata 手动重试", err))
+		return
+	}
+	slog.Info("metadata auto published", "summary", summary)
+}
+
+func (a *App) notifyAdmin(ctx context.Context, text string) {
+	if a.TG == nil || a.Cfg == nil || a.Cfg.TGAdminChatID == 0 {
+		return
+	}
+	if err := a.TG.SendText(ctx, a.Cfg.TGAdminChatID, text); err != nil {
+		slog.Warn("notify admin failed", "err", err)
+	}
+}
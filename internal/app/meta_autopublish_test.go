@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/storage"
+)
+
+func TestMetadataAutoPublishCoalescesAndRearms(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestAPIApp(t, 0)
+	a.Gallery.Processor = fakeUploadProcessor{}
+	a.Gallery.VariantWidths = nil
+	a.Cfg.MetadataAutoPublish = true
+	a.Cfg.TGAdminChatID = 7
+	tg := &fakeTG{notify: make(chan string, 4)}
+	a.TG = tg
+	a.autoPublish.delay = 200 * time.Millisecond
+
+	fs := a.Gallery.Store.(*storage.FSClient)
+	if err := fs.PutObject(ctx, "random.js", []byte(`var counts = {"h":0,"v":0};`), "application/javascript"); err != nil {
+		t.Fatalf("seed random.js: %v", err)
+	}
+	n := 0
+	store := func() {
+		t.Helper()
+		n++
+		res, err := a.storeToGallery(ctx, gallery.StoreInput{Source: "tg", SourceKey: fmt.Sprintf("k%d", n), RawData: []byte(fmt.Sprintf("image %d", n))})
+		if err != nil || !res.Added {
+			t.Fatalf("store %d = %+v, %v", n, res, err)
+		}
+	}
+	publishedH := func() int64 {
+		raw, _, err := fs.GetObject(ctx, "counts.json")
+		if err != nil {
+			return -1
+		}
+		var meta galleryMetadata
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			t.Fatalf("counts.json %s: %v", raw, err)
+		}
+		return meta.H
+	}
+	waitPublished := func(h int64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for publishedH() != h {
+			if time.Now().After(deadline) {
+				t.Fatalf("counts.json h = %d, want %d", publishedH(), h)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A burst is published once, with everything stored during the delay.
+	store()
+	store()
+	store()
+	waitPublished(3)
+	if err := fs.DeleteObject(ctx, "counts.json"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if h := publishedH(); h != -1 {
+		t.Fatalf("burst published again (h=%d), want one publish", h)
+	}
+
+	// The next store after a publish arms a new timer.
+	store()
+	waitPublished(4)
+
+	// A failed publish is reported to the admin chat.
+	if err := fs.DeleteObject(ctx, "random.js"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	store()
+	select {
+	case text := <-tg.notify:
+		if !strings.Contains(text, "自动更新 metadata 失败") || !strings.Contains(text, "random.js") {
+			t.Fatalf("admin notice = %q", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no admin notice after a failed publish")
+	}
+}
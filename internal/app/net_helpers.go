@@ -15,7 +15,19 @@ func downloadWithHeaders(ctx context.Context, sourceURL, referer string) ([]byte
 	return downloadWithHeadersTimeout(ctx, sourceURL, referer, 45*time.Second)
 }
 
+// downloadAuth is HTTP basic auth sent with a download, so credentials stay
+// out of the URL and therefore out of request errors. The zero value sends
+// none.
+type downloadAuth struct {
+	user     string
+	password string
+}
+
 func downloadWithHeadersTimeout(ctx context.Context, sourceURL, referer string, timeout time.Duration) ([]byte, error) {
+	return downloadWithAuthTimeout(ctx, sourceURL, referer, downloadAuth{}, timeout)
+}
+
+func downloadWithAuthTimeout(ctx context.Context, sourceURL, referer string, auth downloadAuth, timeout time.Duration) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
 	if err != nil {
 		return nil, err
@@ -24,6 +36,9 @@ func downloadWithHeadersTimeout(ctx context.Context, sourceURL, referer string,
 	if referer != "" {
 		req.Header.Set("Referer", referer)
 	}
+	if auth.user != "" {
+		req.SetBasicAuth(auth.user, auth.password)
+	}
 	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -37,6 +52,10 @@ func downloadWithHeadersTimeout(ctx context.Context, sourceURL, referer string,
 }
 
 func downloadWithHeadersRetry(ctx context.Context, sourceURL, referer string, timeout time.Duration, retries int, backoff time.Duration) ([]byte, error) {
+	return downloadWithAuthRetry(ctx, sourceURL, referer, downloadAuth{}, timeout, retries, backoff)
+}
+
+func downloadWithAuthRetry(ctx context.Context, sourceURL, referer string, auth downloadAuth, timeout time.Duration, retries int, backoff time.Duration) ([]byte, error) {
 	if retries < 0 {
 		retries = 0
 	}
@@ -49,7 +68,7 @@ func downloadWithHeadersRetry(ctx context.Context, sourceURL, referer string, ti
 	attempts := retries + 1
 	var lastErr error
 	for i := 0; i < attempts; i++ {
-		data, err := downloadWithHeadersTimeout(ctx, sourceURL, referer, timeout)
+		data, err := downloadWithAuthTimeout(ctx, sourceURL, referer, auth, timeout)
 		if err == nil {
 			return data, nil
 		}
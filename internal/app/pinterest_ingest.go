@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"html"
 	"io"
@@ -26,6 +27,13 @@ const (
 
 var pinterestImageURLPattern = regexp.MustCompile(`https?://i\.pinimg\.com/[^\s"'<>\\]+?\.(?:jpg|jpeg|png|webp)(?:\?[^\s"'<>\\]+)?`)
 
+var (
+	pinterestOGTitlePattern  = regexp.MustCompile(`<meta[^>]+property="og:title"[^>]*content="([^"]*)"`)
+	pinterestUsernamePattern = regexp.MustCompile(`"username":("(?:[^"\\]|\\.)*")`)
+	pinterestFullNamePattern = regexp.MustCompile(`"full_name":("(?:[^"\\]|\\.)*")`)
+	pinterestUserIDPattern   = regexp.MustCompile(`"id":"(\d+)"`)
+)
+
 func (a *App) ingestPinterestFromLink(ctx context.Context, item supportedLink) (*TGIngestResult, error) {
 	pin, err := fetchPinterestPin(ctx, item.URL)
 	if err != nil {
@@ -44,34 +52,40 @@ func (a *App) ingestPinterestFromLink(ctx context.Context, item supportedLink) (
 	}
 
 	sourceKey := "pinterest_" + pin.ID
-	storeRes, err := a.Gallery.StoreToGallery(ctx, gallery.StoreInput{
+	storeRes, err := a.storeToGallery(ctx, gallery.StoreInput{
 		Source:       "pinterest",
 		SourceKey:    sourceKey,
 		SourceURL:    pin.SourceURL,
 		SourcePostID: pin.ID,
 		RawData:      data,
 		CollectedAt:  time.Now().Unix(),
+		Title:        pin.Title,
+		AuthorName:   pin.Author,
+		AuthorID:     pin.AuthorID,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	stats := &ingestStats{Title: "Pinterest/" + pin.ID}
 	status := "skipped"
-	added := 0
-	skipped := 1
 	if storeRes.Added {
 		status = fmt.Sprintf("stored %s/%d", storeRes.Image.Orientation, storeRes.Image.Seq)
-		added = 1
-		skipped = 0
-	} else if strings.TrimSpace(storeRes.SkipReason) != "" {
-		status = "skipped: " + strings.TrimSpace(storeRes.SkipReason)
+		stats.Downloaded = 1
+		stats.FirstID = sourceKey
+	} else {
+		stats.Skipped = 1
+		if reason := strings.TrimSpace(storeRes.SkipReason); reason != "" {
+			status = "skipped: " + reason
+		}
 	}
 
 	return &TGIngestResult{
-		ID:        sourceKey,
-		Title:     "Pinterest/" + pin.ID,
+		ID:        stats.FirstID,
+		Title:     stats.Title,
 		SourceURL: pin.SourceURL,
-		Summary:   fmt.Sprintf("Pinterest %s done: +%d, skipped %d (%s)", pin.ID, added, skipped, status),
+		Summary:   fmt.Sprintf("Pinterest %s done: +%d, skipped %d (%s)", pin.ID, stats.Downloaded, stats.Skipped, status),
+		Stats:     stats,
 	}, nil
 }
 
@@ -79,6 +93,10 @@ type pinterestPin struct {
 	ID        string
 	SourceURL string
 	ImageURL  string
+	// Title, Author and AuthorID credit the pin and the user who pinned it.
+	Title    string
+	Author   string
+	AuthorID string
 }
 
 func fetchPinterestPin(ctx context.Context, rawURL string) (pinterestPin, error) {
@@ -110,11 +128,52 @@ func fetchPinterestPin(ctx context.Context, rawURL string) (pinterestPin, error)
 	if sourceURL == "" {
 		sourceURL = finalURL
 	}
-	return pinterestPin{
+	pin := pinterestPin{
 		ID:        pinID,
 		SourceURL: sourceURL,
 		ImageURL:  imageURL,
-	}, nil
+	}
+	pin.Title, pin.Author, pin.AuthorID = extractPinterestCredits(page)
+	return pin, nil
+}
+
+// extractPinterestCredits reads the pin title from og:title and the pinner
+// from the embedded pin JSON. Missing parts are left empty.
+func extractPinterestCredits(page string) (title, author, authorID string) {
+	if m := pinterestOGTitlePattern.FindStringSubmatch(page); len(m) == 2 {
+		title = truncateRunes(html.UnescapeString(m[1]), 120)
+	}
+	for _, variant := range pinterestTextVariants(page) {
+		i := strings.Index(variant, `"pinner":{`)
+		if i < 0 {
+			continue
+		}
+		// The pinner object is flat enough that its own fields come first.
+		window := variant[i:min(len(variant), i+2000)]
+		if end := strings.Index(window, "}"); end > 0 {
+			window = window[:end]
+		}
+		if author = pinterestJSONString(pinterestFullNamePattern, window); author == "" {
+			author = pinterestJSONString(pinterestUsernamePattern, window)
+		}
+		if m := pinterestUserIDPattern.FindStringSubmatch(window); len(m) == 2 {
+			authorID = m[1]
+		}
+		break
+	}
+	return title, author, authorID
+}
+
+func pinterestJSONString(re *regexp.Regexp, text string) string {
+	m := re.FindStringSubmatch(text)
+	if len(m) != 2 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal([]byte(m[1]), &s); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(s)
 }
 
 func fetchPinterestPage(ctx context.Context, rawURL string) (body string, finalURL string, err error) {
@@ -37,3 +37,20 @@ func TestPinterestImageRankingPrefersOriginalsAcrossExtensions(t *testing.T) {
 		t.Fatalf("first expanded = %q, want %q", expanded[0], want)
 	}
 }
+
+func TestExtractPinterestCredits(t *testing.T) {
+	page := `<html><head><meta property="og:title" content="Sunset &amp; clouds"></head>` +
+		`<script>{"pin":{"id":"1076852960912705984","pinner":{"id":"5566","username":"skyfan","full_name":"Sky é Fan","image_small_url":"x"},"board":{"id":"99"}}}</script>`
+	title, author, authorID := extractPinterestCredits(page)
+	if title != "Sunset & clouds" || author != "Sky é Fan" || authorID != "5566" {
+		t.Fatalf("credits = %q, %q, %q", title, author, authorID)
+	}
+
+	_, author, _ = extractPinterestCredits(`{"pinner":{"username":"skyfan","full_name":""}}`)
+	if author != "skyfan" {
+		t.Fatalf("author without full name = %q, want username", author)
+	}
+	if title, author, authorID := extractPinterestCredits("<html></html>"); title+author+authorID != "" {
+		t.Fatalf("credits of a bare page = %q, %q, %q", title, author, authorID)
+	}
+}
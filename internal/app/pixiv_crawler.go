@@ -3,7 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 )
@@ -11,26 +11,50 @@ import (
 const pixivBootstrapStateKey = "pixiv_bootstrap_done"
 
 func (a *App) StartPixivCrawler(ctx context.Context) {
-	if a.Pixiv == nil || a.Cfg == nil || !a.Cfg.HasPixivCrawler() {
-		log.Println("Pixiv crawler disabled (missing PIXIV_PHPSESSID or PIXIV_USER_ID)")
+	if a.Pixiv == nil || a.Cfg == nil || !(a.Cfg.HasPixivCrawler() || a.Cfg.HasPixivArtistCrawler() || a.Cfg.HasPixivFollowCrawler() || a.Cfg.HasPixivRankingCrawler()) {
+		slog.InfoContext(ctx, "pixiv crawler disabled (no bookmarks, PIXIV_ARTIST_IDS, PIXIV_FOLLOW_ENABLED or PIXIV_RANKING_MODES configured)")
 		return
 	}
-	go func() {
-		a.crawlPixivOnce(ctx)
-		ticker := time.NewTicker(time.Duration(maxInt(a.Cfg.PixivIntervalMinutes, 120)) * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				a.crawlPixivOnce(ctx)
-			}
-		}
-	}()
+	interval := time.Duration(maxInt(a.Cfg.PixivIntervalMinutes, 120)) * time.Minute
+	a.crawlEvery(ctx, "pixiv", interval, a.crawlPixivOnce, a.pixivCrawlerNames()...)
+}
+
+// pixivCrawlerNames lists the enabled Pixiv modes under their crawler names.
+func (a *App) pixivCrawlerNames() []string {
+	var names []string
+	if a.Cfg.HasPixivCrawler() {
+		names = append(names, "pixiv_bookmarks")
+	}
+	if a.Cfg.HasPixivArtistCrawler() {
+		names = append(names, "pixiv_artists")
+	}
+	if a.Cfg.HasPixivFollowCrawler() {
+		names = append(names, "pixiv_following")
+	}
+	if a.Cfg.HasPixivRankingCrawler() {
+		names = append(names, "pixiv_ranking")
+	}
+	return names
 }
 
+// crawlPixivOnce runs every enabled Pixiv mode: bookmarks, followed
+// artists, the following feed and rankings.
 func (a *App) crawlPixivOnce(ctx context.Context) {
+	if a.Cfg.HasPixivCrawler() {
+		a.runCrawler(ctx, "pixiv_bookmarks", func(run *crawlRun) { a.crawlPixivBookmarks(ctx, run) })
+	}
+	if a.Cfg.HasPixivArtistCrawler() && ctx.Err() == nil {
+		a.runCrawler(ctx, "pixiv_artists", func(run *crawlRun) { a.crawlPixivArtists(ctx, run, a.Cfg.PixivArtistIDs) })
+	}
+	if a.Cfg.HasPixivFollowCrawler() && ctx.Err() == nil {
+		a.runCrawler(ctx, "pixiv_following", func(run *crawlRun) { a.crawlPixivFollowing(ctx, run) })
+	}
+	if a.Cfg.HasPixivRankingCrawler() && ctx.Err() == nil {
+		a.runCrawler(ctx, "pixiv_ranking", func(run *crawlRun) { a.crawlPixivRankings(ctx, run) })
+	}
+}
+
+func (a *App) crawlPixivBookmarks(ctx context.Context, run *crawlRun) {
 	order := strings.ToLower(strings.TrimSpace(a.Cfg.PixivCrawlOrder))
 	if order == "" {
 		order = "desc"
@@ -40,27 +64,28 @@ func (a *App) crawlPixivOnce(ctx context.Context) {
 		bootstrapDone = true
 	}
 	maxPages := a.resolvePixivMaxPages(bootstrapDone)
-	log.Printf("Pixiv crawl started (mode=%s, order=%s, tag=%q, rest=%q, limit=%d, max_pages=%d)",
-		map[bool]string{true: "incremental", false: "bootstrap"}[bootstrapDone],
-		order, a.Cfg.PixivTag, a.Cfg.PixivRest, maxInt(a.Cfg.PixivLimit, 40), maxPages)
+	slog.InfoContext(ctx, "pixiv crawl started",
+		"mode", map[bool]string{true: "incremental", false: "bootstrap"}[bootstrapDone],
+		"order", order, "tag", a.Cfg.PixivTag, "rest", a.Cfg.PixivRest, "limit", maxInt(a.Cfg.PixivLimit, 40), "max_pages", maxPages)
 
 	var err error
 	if order == "asc" {
-		err = a.crawlPixivAsc(ctx, maxPages)
+		err = a.crawlPixivAsc(ctx, run, maxPages)
 	} else {
-		err = a.crawlPixivDesc(ctx, maxPages)
+		err = a.crawlPixivDesc(ctx, run, maxPages)
 	}
+	run.record(err)
 	if err != nil {
-		log.Printf("Pixiv crawl failed: %v", err)
-		log.Println("Pixiv crawl finished")
+		slog.WarnContext(ctx, "pixiv crawl failed", "err", err)
+		slog.InfoContext(ctx, "pixiv crawl finished")
 		return
 	}
 	if !bootstrapDone {
 		if err := a.DB.SetCrawlerState(ctx, pixivBootstrapStateKey, "1"); err != nil {
-			log.Printf("Pixiv bootstrap state write failed: %v", err)
+			slog.WarnContext(ctx, "pixiv bootstrap state write failed", "err", err)
 		}
 	}
-	log.Println("Pixiv crawl finished")
+	slog.InfoContext(ctx, "pixiv crawl finished")
 }
 
 func (a *App) resolvePixivMaxPages(bootstrapDone bool) int {
@@ -76,7 +101,7 @@ func (a *App) resolvePixivMaxPages(bootstrapDone bool) int {
 	return a.Cfg.PixivMaxPages
 }
 
-func (a *App) crawlPixivDesc(ctx context.Context, maxPages int) error {
+func (a *App) crawlPixivDesc(ctx context.Context, run *crawlRun, maxPages int) error {
 	offset := 0
 	page := 0
 	limit := maxInt(a.Cfg.PixivLimit, 40)
@@ -85,7 +110,8 @@ func (a *App) crawlPixivDesc(ctx context.Context, maxPages int) error {
 		if err != nil {
 			return fmt.Errorf("pixiv bookmarks error: %w", err)
 		}
-		log.Printf("Pixiv page fetched (offset=%d, count=%d, total=%d)", offset, len(ids), total)
+		run.fetched()
+		slog.InfoContext(ctx, "pixiv page fetched", "offset", offset, "count", len(ids), "total", total)
 		if len(ids) == 0 {
 			return nil
 		}
@@ -93,7 +119,7 @@ func (a *App) crawlPixivDesc(ctx context.Context, maxPages int) error {
 			if ctx.Err() != nil {
 				return nil
 			}
-			a.processPixivID(ctx, id)
+			a.processPixivID(ctx, run, id)
 		}
 		page++
 		offset += limit
@@ -104,7 +130,7 @@ func (a *App) crawlPixivDesc(ctx context.Context, maxPages int) error {
 	}
 }
 
-func (a *App) crawlPixivAsc(ctx context.Context, maxPages int) error {
+func (a *App) crawlPixivAsc(ctx context.Context, run *crawlRun, maxPages int) error {
 	offset := 0
 	page := 0
 	limit := maxInt(a.Cfg.PixivLimit, 40)
@@ -114,6 +140,7 @@ func (a *App) crawlPixivAsc(ctx context.Context, maxPages int) error {
 		if err != nil {
 			return fmt.Errorf("pixiv bookmarks error: %w", err)
 		}
+		run.fetched()
 		if len(ids) == 0 {
 			break
 		}
@@ -129,7 +156,7 @@ func (a *App) crawlPixivAsc(ctx context.Context, maxPages int) error {
 		if ctx.Err() != nil {
 			return nil
 		}
-		a.processPixivID(ctx, allIDs[i])
+		a.processPixivID(ctx, run, allIDs[i])
 	}
 	return nil
 }
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"tyr-blog-img/internal/pixiv"
+)
+
+// tagSet matches tags case-insensitively.
+type tagSet map[string]struct{}
+
+func newTagSet(tags []string) tagSet {
+	set := make(tagSet, len(tags))
+	for _, t := range tags {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			set[t] = struct{}{}
+		}
+	}
+	return set
+}
+
+func (s tagSet) has(tag string) bool {
+	_, ok := s[strings.ToLower(strings.TrimSpace(tag))]
+	return ok
+}
+
+// pixivContentFilter is checked against the artwork detail before any page
+// is downloaded, for every Pixiv entry point.
+type pixivContentFilter struct {
+	include      tagSet
+	exclude      tagSet
+	maxXRestrict int
+	excludeAI    bool
+}
+
+func (a *App) pixivContentFilter() pixivContentFilter {
+	return pixivContentFilter{
+		include:      newTagSet(a.Cfg.PixivIncludeTags),
+		exclude:      newTagSet(a.Cfg.PixivExcludeTags),
+		maxXRestrict: a.Cfg.PixivMaxXRestrict,
+		excludeAI:    a.Cfg.PixivExcludeAI,
+	}
+}
+
+// skipReason returns why the artwork must not be stored, or "" if it may.
+func (f pixivContentFilter) skipReason(d *pixiv.DetailResp) string {
+	if x := d.Body.XRestrict; x > f.maxXRestrict {
+		switch x {
+		case pixiv.XRestrictR18:
+			return "R-18"
+		case pixiv.XRestrictR18G:
+			return "R-18G"
+		default:
+			return fmt.Sprintf("xRestrict=%d", x)
+		}
+	}
+	if f.excludeAI && d.Body.AIType == pixiv.AITypeGenerated {
+		return "AI generated"
+	}
+	included := len(f.include) == 0
+	for _, t := range d.Body.Tags.Tags {
+		if f.exclude.has(t.Tag) {
+			return "tag " + t.Tag
+		}
+		if f.include.has(t.Tag) {
+			included = true
+		}
+	}
+	if !included {
+		return "no included tag"
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"testing"
+
+	"tyr-blog-img/internal/pixiv"
+)
+
+func pixivDetail(xRestrict, aiType int, tags ...string) *pixiv.DetailResp {
+	d := &pixiv.DetailResp{}
+	d.Body.XRestrict = xRestrict
+	d.Body.AIType = aiType
+	for _, t := range tags {
+		d.Body.Tags.Tags = append(d.Body.Tags.Tags, struct {
+			Tag string `json:"tag"`
+		}{Tag: t})
+	}
+	return d
+}
+
+func TestPixivContentFilterSkipReason(t *testing.T) {
+	f := pixivContentFilter{
+		include:   newTagSet([]string{"原神"}),
+		exclude:   newTagSet([]string{"R-18", "R-18G"}),
+		excludeAI: true,
+	}
+	cases := []struct {
+		name   string
+		detail *pixiv.DetailResp
+		want   string
+	}{
+		{"allowed", pixivDetail(0, pixiv.AITypeNotAI, "原神", "甘雨"), ""},
+		{"x restrict", pixivDetail(pixiv.XRestrictR18, 0, "原神"), "R-18"},
+		{"excluded tag", pixivDetail(0, 0, "原神", "r-18"), "tag r-18"},
+		{"ai", pixivDetail(0, pixiv.AITypeGenerated, "原神"), "AI generated"},
+		{"not included", pixivDetail(0, 0, "甘雨"), "no included tag"},
+	}
+	for _, tc := range cases {
+		if got := f.skipReason(tc.detail); got != tc.want {
+			t.Fatalf("%s: skipReason = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	f.maxXRestrict = pixiv.XRestrictR18
+	if got := f.skipReason(pixivDetail(pixiv.XRestrictR18G, 0, "原神")); got != "R-18G" {
+		t.Fatalf("R-18G with max R-18: skipReason = %q", got)
+	}
+}
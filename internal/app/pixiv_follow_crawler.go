@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pixivArtistStatePrefix = "pixiv_artist_last_"
+	pixivFollowStateKey    = "pixiv_follow_last"
+)
+
+// crawlPixivArtists ingests new works of every user in userIDs (normally
+// PIXIV_ARTIST_IDS). Works go oldest first and each run is capped, so the
+// first run of a prolific artist is spread over several ticks instead of
+// blocking the crawler.
+func (a *App) crawlPixivArtists(ctx context.Context, run *crawlRun, userIDs []string) {
+	limit := maxInt(a.Cfg.PixivArtistMaxPerRun, 30)
+	slog.InfoContext(ctx, "pixiv artist crawl started", "artists", len(userIDs), "max_per_run", limit)
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		ids, err := a.Pixiv.FetchUserIllustIDs(userID)
+		run.record(err)
+		if err != nil {
+			slog.WarnContext(ctx, "pixiv artist crawl failed", "user", userID, "err", err)
+			continue
+		}
+		run.fetched()
+		stateKey := pixivArtistStatePrefix + userID
+		fresh := a.newerPixivIDs(ctx, stateKey, ids)
+		if len(fresh) > limit {
+			fresh = fresh[:limit]
+		}
+		slog.InfoContext(ctx, "pixiv artist works", "user", userID, "works", len(ids), "new", len(fresh))
+		a.ingestPixivIDsAdvancing(ctx, run, stateKey, fresh)
+		time.Sleep(2 * time.Second)
+	}
+	slog.InfoContext(ctx, "pixiv artist crawl finished")
+}
+
+// crawlPixivFollowing walks the following feed until it reaches a work seen
+// on a previous run, or PIXIV_FOLLOW_MAX_PAGES pages.
+func (a *App) crawlPixivFollowing(ctx context.Context, run *crawlRun) {
+	maxPages := maxInt(a.Cfg.PixivFollowMaxPages, 3)
+	lastID := a.pixivStateID(ctx, pixivFollowStateKey)
+	slog.InfoContext(ctx, "pixiv following crawl started", "max_pages", maxPages, "last", lastID)
+
+	var (
+		ids      []string
+		fetchErr error
+	)
+	for page := 1; page <= maxPages; page++ {
+		pageIDs, err := a.Pixiv.FetchFollowingIDs(page)
+		if err != nil {
+			slog.WarnContext(ctx, "pixiv following page failed", "page", page, "err", err)
+			fetchErr = err
+			break
+		}
+		run.fetched()
+		if len(pageIDs) == 0 {
+			break
+		}
+		ids = append(ids, pageIDs...)
+		if lastID > 0 && pixivIDNum(pageIDs[len(pageIDs)-1]) <= lastID {
+			break
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	// Later pages failing still leaves the fetched ones to ingest.
+	if len(ids) > 0 {
+		fetchErr = nil
+	}
+	run.record(fetchErr)
+
+	fresh := a.newerPixivIDs(ctx, pixivFollowStateKey, ids)
+	slog.InfoContext(ctx, "pixiv following feed", "feed", len(ids), "new", len(fresh))
+	a.ingestPixivIDsAdvancing(ctx, run, pixivFollowStateKey, fresh)
+	slog.InfoContext(ctx, "pixiv following crawl finished")
+}
+
+// newerPixivIDs keeps ids above the saved high-water mark, oldest first.
+func (a *App) newerPixivIDs(ctx context.Context, stateKey string, ids []string) []string {
+	lastID := a.pixivStateID(ctx, stateKey)
+	seen := make(map[string]struct{}, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		if n := pixivIDNum(id); n > lastID {
+			out = append(out, id)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return pixivIDNum(out[i]) < pixivIDNum(out[j]) })
+	return out
+}
+
+// ingestPixivIDsAdvancing ingests ids in order and moves the state key to
+// the last fully ingested id. It stops at the first work that errors or has
+// failed pages, so the mark stays below it and the next run retries it; an
+// artwork error queues no ingest job, so nothing else would.
+func (a *App) ingestPixivIDsAdvancing(ctx context.Context, run *crawlRun, stateKey string, ids []string) {
+	lastID := a.pixivStateID(ctx, stateKey)
+	highestID := lastID
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		stats, err := a.ingestPixivArtwork(ctx, id, "")
+		run.addStats(stats, err)
+		if err != nil {
+			slog.WarnContext(ctx, "pixiv ingest failed", "id", id, "err", err)
+			break
+		}
+		if stats.SkipReason != "" {
+			// Filtered works count as handled so the mark moves past them.
+			slog.InfoContext(ctx, "pixiv ingest filtered", "id", id, "reason", stats.SkipReason)
+		} else {
+			slog.InfoContext(ctx, "pixiv ingest done", "id", id, "added", stats.Downloaded, "skipped", stats.Skipped, "failed", stats.Failed)
+		}
+		if stats.Failed > 0 {
+			break
+		}
+		if n := pixivIDNum(id); n > highestID {
+			highestID = n
+		}
+	}
+	if highestID > lastID {
+		if err := a.DB.SetCrawlerState(ctx, stateKey, strconv.FormatInt(highestID, 10)); err != nil {
+			slog.WarnContext(ctx, "pixiv state update failed", "key", stateKey, "err", err)
+		}
+	}
+}
+
+func (a *App) pixivStateID(ctx context.Context, stateKey string) int64 {
+	val, _, err := a.DB.GetCrawlerState(ctx, stateKey)
+	if err != nil {
+		return 0
+	}
+	return pixivIDNum(val)
+}
+
+func pixivIDNum(id string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+	return n
+}
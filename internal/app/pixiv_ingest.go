@@ -3,10 +3,13 @@ package app
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
 	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/logging"
+	"tyr-blog-img/internal/pixiv"
 )
 
 func (a *App) ingestPixivFromLink(ctx context.Context, item supportedLink) (*TGIngestResult, error) {
@@ -14,11 +17,20 @@ func (a *App) ingestPixivFromLink(ctx context.Context, item supportedLink) (*TGI
 	if err != nil {
 		return nil, err
 	}
+	if stats.SkipReason != "" {
+		return &TGIngestResult{
+			Title:     stats.Title,
+			SourceURL: item.URL,
+			Summary:   fmt.Sprintf("Pixiv %s skipped: %s", item.ID, stats.SkipReason),
+			Stats:     stats,
+		}, nil
+	}
 	return &TGIngestResult{
 		ID:        stats.FirstID,
 		Title:     stats.Title,
 		SourceURL: item.URL,
 		Summary:   fmt.Sprintf("Pixiv %s done: +%d, skipped %d, failed %d", item.ID, stats.Downloaded, stats.Skipped, stats.Failed),
+		Stats:     stats,
 	}, nil
 }
 
@@ -26,10 +38,19 @@ func (a *App) ingestPixivArtwork(ctx context.Context, artworkID, sourceURL strin
 	if a.Pixiv == nil {
 		return nil, fmt.Errorf("pixiv client not configured")
 	}
+	ctx = logging.WithIngest(ctx, "pixiv", artworkID)
 	detail, err := a.Pixiv.FetchDetail(artworkID)
 	if err != nil {
 		return nil, err
 	}
+	stats := &ingestStats{Title: strings.TrimSpace(detail.Body.Title)}
+	if stats.Title == "" {
+		stats.Title = "Pixiv/" + artworkID
+	}
+	if reason := a.pixivContentFilter().skipReason(detail); reason != "" {
+		stats.SkipReason = reason
+		return stats, nil
+	}
 	pages, err := a.Pixiv.FetchPages(artworkID)
 	if err != nil {
 		return nil, err
@@ -40,11 +61,11 @@ func (a *App) ingestPixivArtwork(ctx context.Context, artworkID, sourceURL strin
 	if strings.TrimSpace(sourceURL) == "" {
 		sourceURL = fmt.Sprintf("https://www.pixiv.net/artworks/%s", artworkID)
 	}
-
-	stats := &ingestStats{Title: strings.TrimSpace(detail.Body.Title)}
-	if stats.Title == "" {
-		stats.Title = "Pixiv/" + artworkID
+	tags := make([]string, 0, len(detail.Body.Tags.Tags))
+	for _, t := range detail.Body.Tags.Tags {
+		tags = append(tags, t.Tag)
 	}
+
 	for i, p := range pages {
 		if ctx.Err() != nil {
 			return stats, ctx.Err()
@@ -58,21 +79,37 @@ func (a *App) ingestPixivArtwork(ctx context.Context, artworkID, sourceURL strin
 			stats.Skipped++
 			continue
 		}
-		data, err := a.Pixiv.Download(p.URL)
-		if err != nil {
-			stats.Failed++
-			continue
-		}
-		storeRes, err := a.Gallery.StoreToGallery(ctx, gallery.StoreInput{
+		in := gallery.StoreInput{
 			Source:       "pixiv",
 			SourceKey:    sourceKey,
 			SourceURL:    sourceURL,
 			SourcePostID: artworkID,
-			RawData:      data,
-			CollectedAt:  time.Now().Unix(),
-		})
+			Title:        detail.Body.Title,
+			AuthorName:   detail.Body.UserName,
+			AuthorID:     detail.Body.UserID,
+			Tags:         tags,
+		}
+		retry := ingestRetry{Kind: ingestJobKindPixiv, URLs: []string{p.URL}}
+		var data []byte
+		if detail.Body.IllustType == pixiv.IllustTypeUgoira {
+			// pages only lists the first frame; store the whole animation.
+			retry = ingestRetry{Kind: ingestJobKindUgoira, URLs: []string{sourceURL}}
+			data, err = a.downloadPixivUgoira(ctx, artworkID)
+		} else {
+			data, err = a.Pixiv.Download(p.URL)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "pixiv download failed", "page", i, "ugoira", detail.Body.IllustType == pixiv.IllustTypeUgoira, "err", err)
+			stats.Failed++
+			a.enqueueIngestRetry(ctx, in, retry, err)
+			continue
+		}
+		in.RawData = data
+		in.CollectedAt = time.Now().Unix()
+		storeRes, err := a.storeToGallery(ctx, in)
 		if err != nil {
 			stats.Failed++
+			a.enqueueIngestRetry(ctx, in, retry, err)
 			continue
 		}
 		if storeRes.Added {
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"tyr-blog-img/internal/pixiv"
+)
+
+const (
+	pixivRankingStatePrefix = "pixiv_ranking_last_"
+	// pixivRankingMaxPages bounds how deep we look for N matching entries;
+	// each page holds 50 ranks.
+	pixivRankingMaxPages = 4
+)
+
+// pixivRankingFilter decides which ranking entries are worth ingesting.
+// Tags compare case-insensitively; orientation is "h", "v" or "" for any.
+type pixivRankingFilter struct {
+	include     tagSet
+	exclude     tagSet
+	minWidth    int
+	minHeight   int
+	orientation string
+}
+
+func newPixivRankingFilter(include, exclude []string, minWidth, minHeight int, orientation string) pixivRankingFilter {
+	return pixivRankingFilter{
+		include:     newTagSet(include),
+		exclude:     newTagSet(exclude),
+		minWidth:    minWidth,
+		minHeight:   minHeight,
+		orientation: orientation,
+	}
+}
+
+func (f pixivRankingFilter) match(e pixiv.RankingEntry) bool {
+	if e.Width < f.minWidth || e.Height < f.minHeight {
+		return false
+	}
+	if f.orientation == "h" || f.orientation == "v" {
+		o := "h"
+		if e.Height > e.Width {
+			o = "v"
+		}
+		if o != f.orientation {
+			return false
+		}
+	}
+	included := len(f.include) == 0
+	for _, t := range e.Tags {
+		if f.exclude.has(t) {
+			return false
+		}
+		if f.include.has(t) {
+			included = true
+		}
+	}
+	return included
+}
+
+func (a *App) crawlPixivRankings(ctx context.Context, run *crawlRun) {
+	filter := newPixivRankingFilter(
+		a.Cfg.PixivRankingIncludeTags,
+		a.Cfg.PixivRankingExcludeTags,
+		a.Cfg.PixivRankingMinWidth,
+		a.Cfg.PixivRankingMinHeight,
+		a.Cfg.PixivRankingOrientation,
+	)
+	for _, mode := range a.Cfg.PixivRankingModes {
+		if ctx.Err() != nil {
+			return
+		}
+		run.record(a.crawlPixivRanking(ctx, run, strings.ToLower(strings.TrimSpace(mode)), filter))
+	}
+}
+
+// crawlPixivRanking ingests the top PIXIV_RANKING_TOP matching entries of
+// the latest ranking of mode, once per ranking date. The error reports
+// whether the ranking could be read at all.
+func (a *App) crawlPixivRanking(ctx context.Context, run *crawlRun, mode string, filter pixivRankingFilter) error {
+	content := strings.ToLower(strings.TrimSpace(a.Cfg.PixivRankingContent))
+	stateKey := pixivRankingStatePrefix + mode + "_" + content
+	lastDate, _, err := a.DB.GetCrawlerState(ctx, stateKey)
+	if err != nil {
+		slog.WarnContext(ctx, "pixiv ranking state read failed", "mode", mode, "err", err)
+		return err
+	}
+	top := maxInt(a.Cfg.PixivRankingTop, 20)
+
+	var (
+		date   string
+		picked []pixiv.RankingEntry
+	)
+	for page := 1; page <= pixivRankingMaxPages && len(picked) < top; page++ {
+		resp, err := a.Pixiv.FetchRanking(mode, content, date, page)
+		if err != nil {
+			slog.WarnContext(ctx, "pixiv ranking fetch failed", "mode", mode, "page", page, "err", err)
+			return err
+		}
+		run.fetched()
+		if date == "" {
+			// Pin later pages to the date of the first one.
+			date = resp.Date
+			if date != "" && date <= lastDate {
+				slog.InfoContext(ctx, "pixiv ranking already processed", "mode", mode, "date", date)
+				return nil
+			}
+		}
+		for _, e := range resp.Contents {
+			if len(picked) >= top {
+				break
+			}
+			if filter.match(e) {
+				picked = append(picked, e)
+			}
+		}
+		if len(resp.Contents) == 0 || page*50 >= resp.RankTotal {
+			break
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	slog.InfoContext(ctx, "pixiv ranking crawl", "mode", mode, "content", content, "date", date, "picked", len(picked))
+	handled := true
+	for _, e := range picked {
+		if ctx.Err() != nil {
+			// Leave the date unrecorded so the rest is picked up next run.
+			return nil
+		}
+		if !a.processPixivID(ctx, run, e.ID()) {
+			handled = false
+		}
+	}
+	if date == "" {
+		return nil
+	}
+	if !handled {
+		// Retry the whole ranking next run; works already stored are skipped.
+		slog.WarnContext(ctx, "pixiv ranking incomplete, date not recorded", "mode", mode, "date", date)
+		return nil
+	}
+	if err := a.DB.SetCrawlerState(ctx, stateKey, date); err != nil {
+		slog.WarnContext(ctx, "pixiv ranking state update failed", "mode", mode, "err", err)
+	}
+	return nil
+}
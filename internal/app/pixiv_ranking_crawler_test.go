@@ -0,0 +1,30 @@
+package app
+
+import (
+	"testing"
+
+	"tyr-blog-img/internal/pixiv"
+)
+
+func TestPixivRankingFilter(t *testing.T) {
+	f := newPixivRankingFilter([]string{"Genshin"}, []string{"R-18"}, 1000, 0, "v")
+	cases := []struct {
+		name  string
+		entry pixiv.RankingEntry
+		want  bool
+	}{
+		{"match", pixiv.RankingEntry{Width: 1200, Height: 1800, Tags: []string{"genshin", "ganyu"}}, true},
+		{"excluded tag", pixiv.RankingEntry{Width: 1200, Height: 1800, Tags: []string{"genshin", "r-18"}}, false},
+		{"missing include", pixiv.RankingEntry{Width: 1200, Height: 1800, Tags: []string{"ganyu"}}, false},
+		{"too small", pixiv.RankingEntry{Width: 800, Height: 1800, Tags: []string{"genshin"}}, false},
+		{"landscape", pixiv.RankingEntry{Width: 1800, Height: 1200, Tags: []string{"genshin"}}, false},
+	}
+	for _, tc := range cases {
+		if got := f.match(tc.entry); got != tc.want {
+			t.Fatalf("%s: match = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if !newPixivRankingFilter(nil, nil, 0, 0, "").match(pixiv.RankingEntry{Width: 10, Height: 10}) {
+		t.Fatal("empty filter should accept everything")
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/pixiv"
+)
+
+// downloadPixivUgoira fetches the frame zip of an ugoira work and assembles
+// it into an animated WebP with the original frame delays.
+func (a *App) downloadPixivUgoira(ctx context.Context, artworkID string) ([]byte, error) {
+	enc, ok := a.Gallery.Processor.(gallery.AnimationEncoder)
+	if !ok {
+		return nil, fmt.Errorf("gallery processor cannot encode animations")
+	}
+	meta, err := a.Pixiv.FetchUgoiraMeta(artworkID)
+	if err != nil {
+		return nil, fmt.Errorf("ugoira meta: %w", err)
+	}
+	zipData, err := a.Pixiv.Download(meta.ZipURL())
+	if err != nil {
+		return nil, fmt.Errorf("ugoira zip: %w", err)
+	}
+	frames, err := pixiv.ExtractUgoiraFrames(zipData, meta)
+	if err != nil {
+		return nil, err
+	}
+	anim := make([]gallery.AnimationFrame, len(frames))
+	for i, data := range frames {
+		anim[i] = gallery.AnimationFrame{Data: data, DelayMS: meta.Frames[i].Delay}
+	}
+	return enc.EncodeAnimation(ctx, anim)
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tyr-blog-img/internal/logging"
+
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	// tgAlbumWindow is how long we wait after the last message of a media
+	// group before ingesting it; Telegram delivers album parts as separate
+	// updates within a second or two.
+	tgAlbumWindow       = 2500 * time.Millisecond
+	tgAlbumFlushTimeout = 10 * time.Minute
+)
+
+type tgAlbumBuffer struct {
+	mu     sync.Mutex
+	groups map[string]*tgAlbum
+	// window overrides tgAlbumWindow when set.
+	window time.Duration
+}
+
+type tgAlbum struct {
+	chatID   int64
+	groupID  string
+	messages []*models.Message
+	timer    *time.Timer
+}
+
+func (a *App) bufferTGAlbum(msg *models.Message) {
+	key := fmt.Sprintf("%d:%s", msg.Chat.ID, msg.MediaGroupID)
+
+	a.albums.mu.Lock()
+	defer a.albums.mu.Unlock()
+	if a.albums.groups == nil {
+		a.albums.groups = make(map[string]*tgAlbum)
+	}
+	album, ok := a.albums.groups[key]
+	if !ok {
+		album = &tgAlbum{chatID: msg.Chat.ID, groupID: msg.MediaGroupID}
+		a.albums.groups[key] = album
+		album.timer = time.AfterFunc(a.albums.wait(), func() { a.flushTGAlbum(key) })
+	} else {
+		album.timer.Reset(a.albums.wait())
+	}
+	album.messages = append(album.messages, msg)
+}
+
+func (b *tgAlbumBuffer) wait() time.Duration {
+	if b.window > 0 {
+		return b.window
+	}
+	return tgAlbumWindow
+}
+
+func (a *App) flushTGAlbum(key string) {
+	a.albums.mu.Lock()
+	album := a.albums.groups[key]
+	delete(a.albums.groups, key)
+	a.albums.mu.Unlock()
+	if album == nil || len(album.messages) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tgAlbumFlushTimeout)
+	defer cancel()
+
+	ctx = logging.WithIngest(ctx, "tg_album", fmt.Sprintf("%d_%s", album.chatID, album.groupID))
+
+	summary := a.ingestTGAlbum(ctx, album)
+	if err := a.TG.SendText(ctx, album.chatID, summary); err != nil {
+		slog.WarnContext(ctx, "tg album reply failed", "err", err)
+	}
+}
+
+func (a *App) ingestTGAlbum(ctx context.Context, album *tgAlbum) string {
+	msgs := append([]*models.Message(nil), album.messages...)
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	postID := fmt.Sprintf("%d_%s", album.chatID, album.groupID)
+
+	var (
+		lines                  []string
+		added, skipped, failed int
+		lastCounts             string
+	)
+	for i, msg := range msgs {
+		label := fmt.Sprintf("#%d", i+1)
+		media, ok := extractIncomingMedia(msg)
+		if !ok || !media.isImage() {
+			skipped++
+			lines = append(lines, label+" 跳过（视频/GIF）")
+			continue
+		}
+		stored, err := a.storeTGImage(ctx, msg, media, postID)
+		if err != nil {
+			failed++
+			lines = append(lines, fmt.Sprintf("%s 失败：%v", label, err))
+			continue
+		}
+		res := stored.Result
+		if !res.Added {
+			skipped++
+			reason := strings.TrimSpace(res.SkipReason)
+			if reason == "" {
+				reason = "skipped"
+			}
+			lines = append(lines, fmt.Sprintf("%s 跳过（%s）", label, reason))
+			continue
+		}
+		added++
+		lines = append(lines, fmt.Sprintf("%s 已入库 %s/%d", label, res.Image.Orientation, res.Image.Seq))
+		lastCounts = fmt.Sprintf("counts: h=%d v=%d", res.Counts.H, res.Counts.V)
+	}
+
+	header := fmt.Sprintf("TG相册 %d 张：+%d, skipped %d, failed %d", len(msgs), added, skipped, failed)
+	out := append([]string{header}, lines...)
+	if lastCounts != "" {
+		out = append(out, lastCounts)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// fakeTG serves files from memory and records every message sent.
+type fakeTG struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	sent  []string
+	// notify receives each sent text, if set.
+	notify chan string
+}
+
+func (f *fakeTG) DownloadFile(_ context.Context, fileID string) ([]byte, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.files[fileID]
+	if !ok {
+		return nil, "", fmt.Errorf("file %s not found", fileID)
+	}
+	return data, "photos/" + fileID + ".jpg", nil
+}
+
+func (f *fakeTG) SendText(_ context.Context, _ int64, text string) error {
+	f.mu.Lock()
+	f.sent = append(f.sent, text)
+	f.mu.Unlock()
+	if f.notify != nil {
+		f.notify <- text
+	}
+	return nil
+}
+
+func (f *fakeTG) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+func tgAlbumPhoto(id int, fileID string) *models.Message {
+	return &models.Message{
+		ID:           id,
+		Chat:         models.Chat{ID: 42},
+		From:         &models.User{ID: 7, FirstName: "Ann"},
+		MediaGroupID: "g1",
+		Photo:        []models.PhotoSize{{FileID: fileID, FileUniqueID: "u" + fileID}},
+	}
+}
+
+func TestTGAlbumIngestsPartsInOrderWithOneReply(t *testing.T) {
+	a, _ := newTestAPIApp(t, 0)
+	a.Gallery.Processor = fakeUploadProcessor{}
+	a.Gallery.VariantWidths = nil
+	tg := &fakeTG{
+		files: map[string][]byte{
+			"f1": []byte("first image"),
+			"f3": []byte("third image"),
+		},
+		notify: make(chan string, 4),
+	}
+	a.TG = tg
+	a.albums.window = 50 * time.Millisecond
+
+	// Telegram may deliver parts out of order; f2 cannot be downloaded.
+	for _, msg := range []*models.Message{tgAlbumPhoto(12, "f3"), tgAlbumPhoto(10, "f1"), tgAlbumPhoto(11, "f2")} {
+		a.bufferTGAlbum(msg)
+	}
+
+	var reply string
+	select {
+	case reply = <-tg.notify:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no album reply")
+	}
+	want := []string{
+		"TG相册 3 张：+2, skipped 0, failed 1",
+		"#1 已入库 h/1",
+		"#2 失败：file f2 not found",
+		"#3 已入库 h/2",
+		"counts: h=2 v=0",
+	}
+	if reply != strings.Join(want, "\n") {
+		t.Fatalf("reply =\n%s\nwant\n%s", reply, strings.Join(want, "\n"))
+	}
+
+	ctx := context.Background()
+	for seq, msgID := range map[int64]int{1: 10, 2: 12} {
+		img, ok, err := a.DB.GetGalleryImage(ctx, "h", seq)
+		if err != nil || !ok {
+			t.Fatalf("GetGalleryImage(h, %d) = %v, %v", seq, ok, err)
+		}
+		wantURL := fmt.Sprintf("tg://chat/42/message/%d", msgID)
+		if img.SourceURL != wantURL || img.SourcePostID != "42_g1" {
+			t.Fatalf("seq %d = %s in post %s, want %s in post 42_g1", seq, img.SourceURL, img.SourcePostID, wantURL)
+		}
+		if img.AuthorName != "Ann" || img.AuthorID != "7" {
+			t.Fatalf("seq %d author = %q (%s), want the sender", seq, img.AuthorName, img.AuthorID)
+		}
+	}
+
+	// The window has passed, so no second reply follows.
+	time.Sleep(100 * time.Millisecond)
+	if sent := tg.messages(); len(sent) != 1 {
+		t.Fatalf("sent %d replies, want 1: %q", len(sent), sent)
+	}
+}
+
+func TestTGImageAuthorPrefersForwardOrigin(t *testing.T) {
+	sender := &models.User{ID: 7, FirstName: "Ann", LastName: "Lee"}
+	cases := []struct {
+		name     string
+		msg      models.Message
+		wantName string
+		wantID   string
+	}{
+		{"sender", models.Message{From: sender}, "Ann Lee", "7"},
+		{"forwarded user", models.Message{From: sender, ForwardOrigin: &models.MessageOrigin{
+			MessageOriginUser: &models.MessageOriginUser{SenderUser: models.User{ID: 9, Username: "artist"}},
+		}}, "artist", "9"},
+		{"hidden user", models.Message{From: sender, ForwardOrigin: &models.MessageOrigin{
+			MessageOriginHiddenUser: &models.MessageOriginHiddenUser{SenderUserName: "Anon Artist"},
+		}}, "Anon Artist", ""},
+		{"channel", models.Message{From: sender, ForwardOrigin: &models.MessageOrigin{
+			MessageOriginChannel: &models.MessageOriginChannel{Chat: models.Chat{ID: -100, Title: "Art Feed"}},
+		}}, "Art Feed", "-100"},
+	}
+	for _, tc := range cases {
+		if name, id := tgImageAuthor(&tc.msg); name != tc.wantName || id != tc.wantID {
+			t.Fatalf("%s: author = %q (%s), want %q (%s)", tc.name, name, id, tc.wantName, tc.wantID)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tyr-blog-img/internal/database"
+)
+
+// tgJobsListLimit is how many dead and pending jobs /jobs prints each.
+const tgJobsListLimit = 10
+
+// handleTGJobs lists the ingest retry queue. "/jobs retry <id>" requeues one
+// dead job and "/jobs retry all" every dead job.
+func (a *App) handleTGJobs(ctx context.Context, args string) (*TGIngestResult, error) {
+	const usage = "Usage: /jobs [retry <id>|all]"
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		return a.listTGJobs(ctx)
+	case len(fields) == 2 && strings.EqualFold(fields[0], "retry"):
+		var id int64
+		if !strings.EqualFold(fields[1], "all") {
+			n, err := strconv.ParseInt(strings.TrimPrefix(fields[1], "#"), 10, 64)
+			if err != nil || n < 1 {
+				return &TGIngestResult{Summary: usage}, nil
+			}
+			id = n
+		}
+		n, err := a.DB.RequeueIngestJobs(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if id > 0 && n == 0 {
+			return &TGIngestResult{Summary: fmt.Sprintf("job #%d is not dead", id)}, nil
+		}
+		return &TGIngestResult{Summary: fmt.Sprintf("requeued %d job(s)", n)}, nil
+	default:
+		return &TGIngestResult{Summary: usage}, nil
+	}
+}
+
+func (a *App) listTGJobs(ctx context.Context) (*TGIngestResult, error) {
+	counts, err := a.DB.CountIngestJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{fmt.Sprintf("ingest jobs: %d pending, %d dead", counts.Pending, counts.Dead)}
+	for _, status := range []string{database.IngestJobDead, database.IngestJobPending} {
+		jobs, err := a.DB.ListIngestJobs(ctx, status, tgJobsListLimit)
+		if err != nil {
+			return nil, err
+		}
+		if len(jobs) == 0 {
+			continue
+		}
+		lines = append(lines, "", status+":")
+		for _, job := range jobs {
+			lines = append(lines, formatTGJob(job))
+		}
+	}
+	if counts.Dead > 0 {
+		lines = append(lines, "", "send /jobs retry <id>|all to requeue dead jobs")
+	}
+	return &TGIngestResult{Summary: strings.Join(lines, "\n")}, nil
+}
+
+func formatTGJob(job database.IngestJob) string {
+	line := fmt.Sprintf("#%d %s, %d attempt(s)", job.ID, job.SourceKey, job.Attempts)
+	if job.Status == database.IngestJobPending {
+		line += ", next " + formatTGTime(job.NextRunAt)
+	}
+	if job.LastError != "" {
+		line += "\n  " + truncateRunes(job.LastError, 120)
+	}
+	return line
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"tyr-blog-img/internal/database"
@@ -48,42 +49,116 @@ func (a *App) handleTGCommand(ctx context.Context, cmd, args string) (*TGIngestR
 	switch strings.ToLower(strings.TrimSpace(cmd)) {
 	case "updata", "update":
 		return a.handleTGUpdateMetadata(ctx)
+	case "del", "delete":
+		return a.handleTGDelete(ctx, args)
+	case "variants":
+		return a.handleTGVariants(ctx, args)
+	case "jobs":
+		return a.handleTGJobs(ctx, args)
+	case "status":
+		return a.handleTGStatus(ctx)
+	case "crawl":
+		return a.handleTGCrawl(ctx, args)
+	case "pause":
+		return a.handleTGPause(ctx, args, true)
+	case "resume":
+		return a.handleTGPause(ctx, args, false)
 	case "start", "help":
-		return &TGIngestResult{Summary: "Commands:\n/updata - refresh counts.json and random*.js counts from D1 seq"}, nil
+		return &TGIngestResult{Summary: strings.Join([]string{
+			"Commands:",
+			"/updata - refresh counts.json and random*.js counts from D1 seq",
+			"/del h|v <seq> - take down an image and back-fill its slot",
+			"/variants [h|v] [limit] - render missing width variants for existing images",
+			"/jobs [retry <id>|all] - list failed downloads queued for retry and requeue dead ones",
+			"/status - last and next run of every crawler, counts and retry backlog",
+			"/crawl pixiv|twitter [user] - run one crawler pass now",
+			"/pause pixiv|twitter|yande|danbooru - stop a crawler until /resume",
+			"/resume pixiv|twitter|yande|danbooru - restart a paused crawler",
+		}, "\n")}, nil
 	default:
 		return &TGIngestResult{Summary: fmt.Sprintf("Unknown command: /%s", strings.TrimSpace(cmd))}, nil
 	}
 }
 
+func (a *App) handleTGDelete(ctx context.Context, args string) (*TGIngestResult, error) {
+	fields := strings.Fields(args)
+	if len(fields) < 2 {
+		return &TGIngestResult{Summary: "Usage: /del h|v <seq> [reason]"}, nil
+	}
+	orientation := strings.ToLower(fields[0])
+	seq, err := strconv.ParseInt(fields[1], 10, 64)
+	if (orientation != "h" && orientation != "v") || err != nil || seq < 1 {
+		return &TGIngestResult{Summary: "Usage: /del h|v <seq> [reason]"}, nil
+	}
+	reason := "tg_del"
+	if len(fields) > 2 {
+		reason = "tg_del: " + strings.Join(fields[2:], " ")
+	}
+
+	res, err := a.Gallery.RemoveImage(ctx, orientation, seq, reason)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{fmt.Sprintf("removed %s/%d (%s), blocked %s", orientation, seq, res.Removed.Source, res.Removed.SourceKey)}
+	if res.Moved != nil {
+		lines = append(lines, fmt.Sprintf("moved %s/%d -> %s/%d", orientation, res.MovedSeq, orientation, seq))
+	}
+
+	// Republish counts so random*.js stops picking the freed tail seq.
+	meta, err := a.handleTGUpdateMetadata(ctx)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("metadata update failed: %v (send /updata to retry)", err))
+	} else if meta != nil {
+		lines = append(lines, meta.Summary)
+	}
+	return &TGIngestResult{Summary: strings.Join(lines, "\n")}, nil
+}
+
 func (a *App) handleTGUpdateMetadata(ctx context.Context) (*TGIngestResult, error) {
+	summary, err := a.publishMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &TGIngestResult{Summary: summary}, nil
+}
+
+// publishMetadata rewrites counts.json, the counts line of random*.js and
+// the manifest from D1. Manual /updata and the auto publisher both go through here and
+// are serialized so two publishes never interleave their writes.
+func (a *App) publishMetadata(ctx context.Context) (string, error) {
 	if a == nil || a.DB == nil || a.Gallery == nil || a.Gallery.Store == nil {
-		return &TGIngestResult{Summary: "metadata publisher is not initialized"}, nil
+		return "", fmt.Errorf("metadata publisher is not initialized")
 	}
 	store, ok := a.Gallery.Store.(metadataPublisherStore)
 	if !ok {
-		return &TGIngestResult{Summary: "current object store does not support metadata publish"}, nil
+		return "", fmt.Errorf("current object store does not support metadata publish")
 	}
 
-	counts, err := a.currentCountsBySeq(ctx)
+	a.publishMu.Lock()
+	defer a.publishMu.Unlock()
+
+	meta, err := a.currentGalleryMetadata(ctx)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	counts := meta.GalleryCounts
 
+	a.invalidateCountsCache()
 	updated := make([]string, 0, 3)
 
 	// counts.json
-	countsJSON, err := json.Marshal(counts)
+	countsJSON, err := json.Marshal(meta)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	if err := store.PutObjectWithCacheControl(ctx, "counts.json", countsJSON, "application/json; charset=utf-8", "public, max-age=30"); err != nil {
-		return nil, fmt.Errorf("upload counts.json: %w", err)
+		return "", fmt.Errorf("upload counts.json: %w", err)
 	}
 	updated = append(updated, "counts.json")
 
 	// random.js
 	if ok, err := a.patchAndUploadRandomScript(ctx, store, "random.js", counts); err != nil {
-		return nil, err
+		return "", err
 	} else if ok {
 		updated = append(updated, "random.js")
 	}
@@ -96,9 +171,56 @@ func (a *App) handleTGUpdateMetadata(ctx context.Context) (*TGIngestResult, erro
 		updated = append(updated, "random-img-only.js")
 	}
 
-	return &TGIngestResult{
-		Summary: fmt.Sprintf("metadata updated\ncounts: h=%d v=%d\nfiles: %s", counts.H, counts.V, strings.Join(updated, ", ")),
-	}, nil
+	summary := fmt.Sprintf("metadata updated\ncounts: h=%d v=%d\nfiles: %s", counts.H, counts.V, strings.Join(updated, ", "))
+	if len(meta.Variants) > 0 {
+		summary += fmt.Sprintf("\nvariants: h=%v v=%v", meta.Variants["h"], meta.Variants["v"])
+	}
+
+	// The manifest is an extra for the gallery page; counts are already out,
+	// so a failure here is reported rather than failing the publish.
+	if res, err := a.publishManifest(ctx, store, counts); err != nil {
+		summary += fmt.Sprintf("\nmanifest failed: %v", err)
+	} else {
+		summary += fmt.Sprintf("\nmanifest: written %d, unchanged %d", res.Written, res.Unchanged)
+		if res.Removed > 0 {
+			summary += fmt.Sprintf(", removed %d", res.Removed)
+		}
+	}
+	return summary, nil
+}
+
+// galleryMetadata is the counts.json payload. Variants lists, per
+// orientation, the widths that exist for every seq (ri/{o}/w{width}/{seq}.webp),
+// so the frontend only asks for a variant once backfill has caught up.
+type galleryMetadata struct {
+	database.GalleryCounts
+	Variants map[string][]int `json:"variants,omitempty"`
+}
+
+func (a *App) currentGalleryMetadata(ctx context.Context) (galleryMetadata, error) {
+	counts, err := a.currentCountsBySeq(ctx)
+	if err != nil {
+		return galleryMetadata{}, err
+	}
+	meta := galleryMetadata{GalleryCounts: counts}
+	variantCounts, err := a.DB.CountGalleryVariants(ctx)
+	if err != nil {
+		return galleryMetadata{}, fmt.Errorf("count variants: %w", err)
+	}
+	for _, vc := range variantCounts {
+		total := counts.H
+		if vc.Orientation == "v" {
+			total = counts.V
+		}
+		if total < 1 || vc.Count < total {
+			continue
+		}
+		if meta.Variants == nil {
+			meta.Variants = make(map[string][]int)
+		}
+		meta.Variants[vc.Orientation] = append(meta.Variants[vc.Orientation], vc.Width)
+	}
+	return meta, nil
 }
 
 func (a *App) currentCountsBySeq(ctx context.Context) (database.GalleryCounts, error) {
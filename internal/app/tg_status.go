@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"tyr-blog-img/internal/database"
+)
+
+// handleTGStatus summarizes crawler health for on-call: the last run and
+// next tick of every crawler, the h/v counts and the retry queue.
+func (a *App) handleTGStatus(ctx context.Context) (*TGIngestResult, error) {
+	var lines []string
+	if meta, err := a.cachedMetadata(ctx); err != nil {
+		lines = append(lines, fmt.Sprintf("counts: unavailable (%v)", err))
+	} else {
+		lines = append(lines, fmt.Sprintf("counts: h=%d v=%d", meta.H, meta.V))
+	}
+
+	jobs, err := a.DB.CountIngestJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	line := fmt.Sprintf("ingest jobs: %d pending, %d dead", jobs.Pending, jobs.Dead)
+	if jobs.Dead > 0 {
+		line += " (see /jobs)"
+	}
+	lines = append(lines, line)
+
+	var paused []string
+	for _, loop := range crawlerLoopNames {
+		if _, ok := a.schedule.loop(loop); ok && a.crawlerPaused(ctx, loop) {
+			paused = append(paused, loop)
+		}
+	}
+	if len(paused) > 0 {
+		lines = append(lines, "paused: "+strings.Join(paused, ", ")+" (/resume to restart)")
+	}
+
+	runs, err := a.DB.LatestCrawlerRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]database.CrawlerRun, len(runs))
+	for _, r := range runs {
+		last[r.Crawler] = r
+	}
+	// Crawlers that are scheduled but have not finished a run yet are listed
+	// too, so a stuck first run is visible.
+	names := make([]string, 0, len(runs))
+	for _, r := range runs {
+		names = append(names, r.Crawler)
+	}
+	for _, name := range a.schedule.names() {
+		if _, ok := last[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		lines = append(lines, "", "no crawler runs recorded")
+	}
+	for _, name := range names {
+		lines = append(lines, "", a.formatTGCrawlerStatus(name, last[name]))
+	}
+	return &TGIngestResult{Summary: strings.Join(lines, "\n")}, nil
+}
+
+func (a *App) formatTGCrawlerStatus(name string, run database.CrawlerRun) string {
+	var b strings.Builder
+	b.WriteString(name + ": ")
+	if run.ID == 0 {
+		b.WriteString("no runs yet")
+	} else {
+		result := "ok"
+		if !run.Success {
+			result = "FAILED"
+		}
+		took := time.Duration(run.FinishedAt-run.StartedAt) * time.Second
+		fmt.Fprintf(&b, "%s at %s (%s)\n  pages %d, +%d, skipped %d, failed %d",
+			result, formatTGTime(run.FinishedAt), took, run.Pages, run.Added, run.Skipped, run.Failed)
+		if run.Error != "" {
+			b.WriteString("\n  last error: " + truncateRunes(run.Error, 120))
+		}
+	}
+	if next, ok := a.schedule.get(name); ok {
+		b.WriteString("\n  next " + formatTGTime(next.Unix()))
+	}
+	return b.String()
+}
+
+func formatTGTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format("01-02 15:04") + " UTC"
+}
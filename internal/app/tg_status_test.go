@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"tyr-blog-img/internal/database"
+)
+
+func TestTGStatusShowsRunsAndBacklog(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestPublicApp(t, 3, 2)
+
+	a.runCrawler(ctx, "pixiv_bookmarks", func(run *crawlRun) {
+		run.fetched()
+		run.fetched()
+		run.record(nil)
+		run.addStats(&ingestStats{Downloaded: 2, Skipped: 1, Failed: 1}, nil)
+		run.addStats(&ingestStats{SkipReason: "r18"}, nil)
+		run.addStats(nil, errors.New("artwork deleted"))
+	})
+	a.runCrawler(ctx, "twitter_author", func(run *crawlRun) { run.record(errors.New("all rss sources failed")) })
+	a.schedule.set(time.Now().Add(time.Hour), "pixiv_bookmarks", "yande")
+
+	if _, err := a.DB.EnqueueIngestJob(ctx, database.IngestJob{Source: "pixiv", SourceKey: "pixiv_1_p0", URL: "https://i.pximg.net/1.png"}); err != nil {
+		t.Fatalf("EnqueueIngestJob: %v", err)
+	}
+
+	res, err := a.handleTGCommand(ctx, "status", "")
+	if err != nil {
+		t.Fatalf("/status: %v", err)
+	}
+	for _, want := range []string{
+		"counts: h=3 v=2",
+		"ingest jobs: 1 pending, 0 dead",
+		"pixiv_bookmarks: ok at ",
+		"pages 2, +2, skipped 2, failed 2",
+		"twitter_author: FAILED at ",
+		"last error: all rss sources failed",
+		"yande: no runs yet\n  next ",
+	} {
+		if !strings.Contains(res.Summary, want) {
+			t.Errorf("/status missing %q in:\n%s", want, res.Summary)
+		}
+	}
+}
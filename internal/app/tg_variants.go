@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultVariantBackfillLimit keeps one /variants run short enough to reply
+// in the same chat turn; send it again to continue.
+const defaultVariantBackfillLimit = 50
+
+func (a *App) handleTGVariants(ctx context.Context, args string) (*TGIngestResult, error) {
+	const usage = "Usage: /variants [h|v] [limit]"
+	orientations := []string{"h", "v"}
+	limit := defaultVariantBackfillLimit
+	for _, f := range strings.Fields(args) {
+		switch f = strings.ToLower(f); f {
+		case "h", "v":
+			orientations = []string{f}
+		default:
+			n, err := strconv.Atoi(f)
+			if err != nil || n < 1 {
+				return &TGIngestResult{Summary: usage}, nil
+			}
+			limit = n
+		}
+	}
+	if a.Gallery == nil {
+		return nil, fmt.Errorf("gallery service is not initialized")
+	}
+
+	lines := make([]string, 0, len(orientations)+1)
+	for _, o := range orientations {
+		res, err := a.Gallery.BackfillVariants(ctx, o, limit)
+		if err != nil {
+			return nil, err
+		}
+		line := fmt.Sprintf("%s: scanned %d, generated %d, failed %d", o, res.Scanned, res.Generated, res.Failed)
+		if res.LastError != "" {
+			line += "\nlast error: " + res.LastError
+		}
+		lines = append(lines, line)
+	}
+
+	// Republish so counts.json advertises widths that are now complete.
+	meta, err := a.handleTGUpdateMetadata(ctx)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("metadata update failed: %v (send /updata to retry)", err))
+	} else if meta != nil {
+		lines = append(lines, meta.Summary)
+	}
+	return &TGIngestResult{Summary: strings.Join(lines, "\n")}, nil
+}
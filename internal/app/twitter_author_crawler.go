@@ -5,7 +5,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	neturl "net/url"
 	"sort"
@@ -36,26 +36,18 @@ type twitterAuthorCandidate struct {
 
 func (a *App) StartTwitterAuthorCrawler(ctx context.Context) {
 	if a.Cfg == nil || !a.Cfg.HasTwitterAuthorCrawler() {
-		log.Println("Twitter author crawler disabled")
+		slog.InfoContext(ctx, "twitter author crawler disabled")
 		return
 	}
-	go func() {
-		a.crawlTwitterAuthorsOnce(ctx)
-		ticker := time.NewTicker(time.Duration(maxInt(a.Cfg.TwitterAuthorIntervalMin, 60)) * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				a.crawlTwitterAuthorsOnce(ctx)
-			}
-		}
-	}()
+	crawl := func(ctx context.Context) {
+		a.runCrawler(ctx, "twitter_author", func(run *crawlRun) { a.crawlTwitterAuthorsOnce(ctx, run) })
+	}
+	interval := time.Duration(maxInt(a.Cfg.TwitterAuthorIntervalMin, 60)) * time.Minute
+	a.crawlEvery(ctx, "twitter", interval, crawl, "twitter_author")
 }
 
-func (a *App) crawlTwitterAuthorsOnce(ctx context.Context) {
-	log.Printf("Twitter author crawl started (users=%d, sources=%d)", len(a.Cfg.TwitterAuthorUsers), len(a.Cfg.TwitterRSSSources))
+func (a *App) crawlTwitterAuthorsOnce(ctx context.Context, run *crawlRun) {
+	slog.InfoContext(ctx, "twitter author crawl started", "users", len(a.Cfg.TwitterAuthorUsers), "sources", len(a.Cfg.TwitterRSSSources))
 	for _, rawUser := range a.Cfg.TwitterAuthorUsers {
 		if ctx.Err() != nil {
 			return
@@ -64,15 +56,17 @@ func (a *App) crawlTwitterAuthorsOnce(ctx context.Context) {
 		if user == "" {
 			continue
 		}
-		if err := a.crawlTwitterAuthorUser(ctx, user); err != nil {
-			log.Printf("Twitter author crawl failed user=%s err=%v", user, err)
+		err := a.crawlTwitterAuthorUser(ctx, run, user)
+		run.record(err)
+		if err != nil {
+			slog.WarnContext(ctx, "twitter author crawl failed", "user", user, "err", err)
 		}
 		time.Sleep(1500 * time.Millisecond)
 	}
-	log.Println("Twitter author crawl finished")
+	slog.InfoContext(ctx, "twitter author crawl finished")
 }
 
-func (a *App) crawlTwitterAuthorUser(ctx context.Context, user string) error {
+func (a *App) crawlTwitterAuthorUser(ctx context.Context, run *crawlRun, user string) error {
 	stateKey := twitterAuthorStatePrefix + strings.ToLower(user)
 	lastValue, ok, err := a.DB.GetCrawlerState(ctx, stateKey)
 	if err != nil {
@@ -87,6 +81,7 @@ func (a *App) crawlTwitterAuthorUser(ctx context.Context, user string) error {
 	if err != nil {
 		return err
 	}
+	run.fetched()
 	if len(links) == 0 {
 		return nil
 	}
@@ -115,8 +110,10 @@ func (a *App) crawlTwitterAuthorUser(ctx context.Context, user string) error {
 		if ctx.Err() != nil {
 			return nil
 		}
-		if _, err := a.ingestTwitterFromLink(ctx, c.Link); err != nil {
-			log.Printf("Twitter author ingest failed user=%s tweet=%s err=%v", user, c.Link.ID, err)
+		stats, err := a.ingestTwitterTweet(ctx, c.Link.ID, c.Link.URL)
+		run.addStats(stats, err)
+		if err != nil {
+			slog.WarnContext(ctx, "twitter author ingest failed", "user", user, "tweet", c.Link.ID, "err", err)
 			continue
 		}
 		if c.ID > highestSuccessID {
@@ -126,7 +123,7 @@ func (a *App) crawlTwitterAuthorUser(ctx context.Context, user string) error {
 	}
 	if highestSuccessID > lastID {
 		if err := a.DB.SetCrawlerState(ctx, stateKey, strconv.FormatInt(highestSuccessID, 10)); err != nil {
-			log.Printf("Twitter author state update failed user=%s err=%v", user, err)
+			slog.WarnContext(ctx, "twitter author state update failed", "user", user, "err", err)
 		}
 	}
 	return nil
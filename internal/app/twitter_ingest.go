@@ -4,13 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	neturl "net/url"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
 	"tyr-blog-img/internal/gallery"
+	"tyr-blog-img/internal/logging"
 )
 
 const defaultTwitterAPIDomain = "fxtwitter.com"
@@ -54,10 +57,12 @@ func (a *App) ingestTwitterFromLink(ctx context.Context, item supportedLink) (*T
 		Title:     stats.Title,
 		SourceURL: item.URL,
 		Summary:   fmt.Sprintf("Twitter %s done: +%d, skipped %d, failed %d", item.ID, stats.Downloaded, stats.Skipped, stats.Failed),
+		Stats:     stats,
 	}, nil
 }
 
 func (a *App) ingestTwitterTweet(ctx context.Context, tweetID, sourceURL string) (*ingestStats, error) {
+	ctx = logging.WithIngest(ctx, "twitter", tweetID)
 	tweet, err := fetchTwitterTweet(ctx, a.Cfg.TwitterAPIDomain, tweetID)
 	if err != nil {
 		return nil, fmt.Errorf("twitter status: %w", err)
@@ -80,21 +85,31 @@ func (a *App) ingestTwitterTweet(ctx context.Context, tweetID, sourceURL string)
 			stats.Skipped++
 			continue
 		}
-		data, err := downloadWithHeaders(ctx, buildTwitterImageURL(rawURL), "https://x.com/")
-		if err != nil {
-			stats.Failed++
-			continue
-		}
-		storeRes, err := a.Gallery.StoreToGallery(ctx, gallery.StoreInput{
+		in := gallery.StoreInput{
 			Source:       "twitter",
 			SourceKey:    sourceKey,
 			SourceURL:    sourceURL,
 			SourcePostID: tweetID,
-			RawData:      data,
-			CollectedAt:  time.Now().Unix(),
-		})
+			Title:        stats.Title,
+			AuthorName:   fallbackTitle(tweet.Author.Name, tweet.Author.Username),
+			AuthorID:     tweet.Author.ID,
+			Tags:         twitterHashtags(tweet.Text),
+		}
+		imageURL := buildTwitterImageURL(rawURL)
+		retry := ingestRetry{Kind: ingestJobKindHTTP, URLs: []string{imageURL}, Referer: "https://x.com/"}
+		data, err := downloadWithHeaders(ctx, imageURL, retry.Referer)
 		if err != nil {
+			slog.WarnContext(ctx, "twitter download failed", "photo", i, "err", err)
 			stats.Failed++
+			a.enqueueIngestRetry(ctx, in, retry, err)
+			continue
+		}
+		in.RawData = data
+		in.CollectedAt = time.Now().Unix()
+		storeRes, err := a.storeToGallery(ctx, in)
+		if err != nil {
+			stats.Failed++
+			a.enqueueIngestRetry(ctx, in, retry, err)
 			continue
 		}
 		if storeRes.Added {
@@ -178,6 +193,17 @@ func fetchTwitterTweet(ctx context.Context, domain, tweetID string) (*twitterTwe
 	return payload.Tweet, nil
 }
 
+var twitterHashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/])[#＃]([\p{L}\p{N}_]+)`)
+
+// twitterHashtags returns the hashtags of a tweet text without the '#'.
+func twitterHashtags(text string) []string {
+	var tags []string
+	for _, m := range twitterHashtagPattern.FindAllStringSubmatch(text, -1) {
+		tags = append(tags, m[1])
+	}
+	return tags
+}
+
 func buildTwitterTitle(text, tweetID, username string) string {
 	text = strings.TrimSpace(text)
 	if text != "" {
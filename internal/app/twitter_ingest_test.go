@@ -0,0 +1,14 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTwitterHashtags(t *testing.T) {
+	got := twitterHashtags("新作 #原神 #Genshin_Impact\n＃甘雨 a#notatag https://x.com/#frag &#39;")
+	want := []string{"原神", "Genshin_Impact", "甘雨"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("twitterHashtags = %v, want %v", got, want)
+	}
+}
@@ -1,7 +1,7 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
@@ -9,6 +9,16 @@ import (
 
 type Config struct {
 	ListenAddr string
+	// APIToken guards /api/v1/*; the API is off when empty.
+	APIToken string
+	// MetricsEnabled serves Prometheus metrics on /metrics.
+	MetricsEnabled bool
+	// LogFormat is text or json; LogLevel is debug, info, warn or error.
+	LogFormat string
+	LogLevel  string
+
+	DBBackend  string
+	SQLitePath string
 
 	D1AccountID  string
 	D1APIToken   string
@@ -16,6 +26,9 @@ type Config struct {
 
 	ImageDomain string
 
+	StorageBackend string
+	FSRoot         string
+
 	R2Endpoint  string
 	R2Region    string
 	R2Bucket    string
@@ -28,6 +41,13 @@ type Config struct {
 	TGWebhookURL           string
 	DeleteWebhookOnPolling bool
 	TGAllowedUserIDs       map[int64]struct{}
+	TGAdminChatID          int64
+
+	MetadataAutoPublish bool
+	// MetadataPublishDelay is how long after the first ingest the metadata
+	// is published; it is a fixed delay, not a debounce (see
+	// METADATA_PUBLISH_DEBOUNCE_SECONDS, named before that was clear).
+	MetadataPublishDelay int
 
 	PixivPHPSESSID           string
 	PixivUserID              string
@@ -39,6 +59,23 @@ type Config struct {
 	PixivBootstrapMaxPages   int
 	PixivIncrementalMaxPages int
 	PixivIntervalMinutes     int
+	PixivArtistIDs           []string
+	PixivArtistMaxPerRun     int
+	PixivFollowEnabled       bool
+	PixivFollowMaxPages      int
+	PixivIncludeTags         []string
+	PixivExcludeTags         []string
+	PixivMaxXRestrict        int
+	PixivExcludeAI           bool
+
+	PixivRankingModes       []string
+	PixivRankingContent     string
+	PixivRankingTop         int
+	PixivRankingIncludeTags []string
+	PixivRankingExcludeTags []string
+	PixivRankingMinWidth    int
+	PixivRankingMinHeight   int
+	PixivRankingOrientation string
 
 	TwitterAPIDomain         string
 	TwitterAuthorEnabled     bool
@@ -47,8 +84,26 @@ type Config struct {
 	TwitterAuthorIntervalMin int
 	TwitterAuthorFetchLimit  int
 
-	GalleryBaselineH int64
-	GalleryBaselineV int64
+	YandeTags        []string
+	YandeIntervalMin int
+	YandeFetchLimit  int
+
+	DanbooruLogin       string
+	DanbooruAPIKey      string
+	DanbooruTags        []string
+	DanbooruIntervalMin int
+	DanbooruFetchLimit  int
+
+	// IngestWorkers retry failed downloads from the ingest_jobs table;
+	// 0 disables them.
+	IngestWorkers        int
+	IngestJobPollSeconds int
+	IngestJobMaxAttempts int
+
+	GalleryBaselineH        int64
+	GalleryBaselineV        int64
+	GalleryPHashMaxDistance int
+	GalleryVariantWidths    []int
 }
 
 func Load() Config {
@@ -63,16 +118,24 @@ func Load() Config {
 	d1DatabaseID := strings.TrimSpace(os.Getenv("D1_DATABASE_ID"))
 
 	return Config{
-		ListenAddr:   envOrDefault("LISTEN_ADDR", ":8080"),
-		D1AccountID:  d1AccountID,
-		D1APIToken:   d1APIToken,
-		D1DatabaseID: d1DatabaseID,
-		ImageDomain:  strings.TrimSpace(os.Getenv("IMAGE_DOMAIN")),
-		R2Endpoint:   strings.TrimSpace(os.Getenv("R2_ENDPOINT")),
-		R2Region:     envOrDefault("R2_REGION", "auto"),
-		R2Bucket:     strings.TrimSpace(os.Getenv("R2_BUCKET")),
-		R2AccessKey:  strings.TrimSpace(os.Getenv("R2_ACCESS_KEY_ID")),
-		R2SecretKey:  strings.TrimSpace(os.Getenv("R2_SECRET_ACCESS_KEY")),
+		ListenAddr:     envOrDefault("LISTEN_ADDR", ":8080"),
+		APIToken:       strings.TrimSpace(os.Getenv("API_TOKEN")),
+		MetricsEnabled: envBool("METRICS_ENABLED", true),
+		LogFormat:      strings.ToLower(envOrDefault("LOG_FORMAT", "text")),
+		LogLevel:       strings.ToLower(envOrDefault("LOG_LEVEL", "info")),
+		DBBackend:      strings.ToLower(envOrDefault("DB_BACKEND", "d1")),
+		SQLitePath:     envOrDefault("SQLITE_PATH", "data/gallery.db"),
+		D1AccountID:    d1AccountID,
+		D1APIToken:     d1APIToken,
+		D1DatabaseID:   d1DatabaseID,
+		ImageDomain:    strings.TrimSpace(os.Getenv("IMAGE_DOMAIN")),
+		StorageBackend: strings.ToLower(envOrDefault("STORAGE_BACKEND", "r2")),
+		FSRoot:         envOrDefault("STORAGE_FS_ROOT", "data/objects"),
+		R2Endpoint:     strings.TrimSpace(os.Getenv("R2_ENDPOINT")),
+		R2Region:       envOrDefault("R2_REGION", "auto"),
+		R2Bucket:       strings.TrimSpace(os.Getenv("R2_BUCKET")),
+		R2AccessKey:    strings.TrimSpace(os.Getenv("R2_ACCESS_KEY_ID")),
+		R2SecretKey:    strings.TrimSpace(os.Getenv("R2_SECRET_ACCESS_KEY")),
 
 		BotToken:               strings.TrimSpace(os.Getenv("BOT_TOKEN")),
 		BotMode:                strings.ToLower(envOrDefault("BOT_MODE", "polling")),
@@ -80,6 +143,10 @@ func Load() Config {
 		TGWebhookURL:           strings.TrimSpace(os.Getenv("TELEGRAM_WEBHOOK_URL")),
 		DeleteWebhookOnPolling: envBool("TELEGRAM_DELETE_WEBHOOK_ON_POLLING", false),
 		TGAllowedUserIDs:       parseIDSet(os.Getenv("TG_ALLOWED_USER_IDS")),
+		TGAdminChatID:          envInt64("TG_ADMIN_CHAT_ID", 0),
+
+		MetadataAutoPublish:  envBool("METADATA_AUTO_PUBLISH", true),
+		MetadataPublishDelay: envInt("METADATA_PUBLISH_DEBOUNCE_SECONDS", 60),
 
 		PixivPHPSESSID:           strings.TrimSpace(os.Getenv("PIXIV_PHPSESSID")),
 		PixivUserID:              strings.TrimSpace(os.Getenv("PIXIV_USER_ID")),
@@ -91,6 +158,23 @@ func Load() Config {
 		PixivBootstrapMaxPages:   envInt("PIXIV_BOOTSTRAP_MAX_PAGES", -1),
 		PixivIncrementalMaxPages: envInt("PIXIV_INCREMENTAL_MAX_PAGES", 2),
 		PixivIntervalMinutes:     envInt("PIXIV_INTERVAL_MINUTES", 120),
+		PixivArtistIDs:           parseStringList(os.Getenv("PIXIV_ARTIST_IDS"), ","),
+		PixivArtistMaxPerRun:     envInt("PIXIV_ARTIST_MAX_PER_RUN", 30),
+		PixivFollowEnabled:       envBool("PIXIV_FOLLOW_ENABLED", false),
+		PixivFollowMaxPages:      envInt("PIXIV_FOLLOW_MAX_PAGES", 3),
+		PixivIncludeTags:         parseStringList(os.Getenv("PIXIV_INCLUDE_TAGS"), ","),
+		PixivExcludeTags:         parseStringList(envOrDefault("PIXIV_EXCLUDE_TAGS", "R-18,R-18G"), ","),
+		PixivMaxXRestrict:        envInt("PIXIV_MAX_X_RESTRICT", 0),
+		PixivExcludeAI:           envBool("PIXIV_EXCLUDE_AI", false),
+
+		PixivRankingModes:       parseStringList(os.Getenv("PIXIV_RANKING_MODES"), ","),
+		PixivRankingContent:     envOrDefault("PIXIV_RANKING_CONTENT", "illust"),
+		PixivRankingTop:         envInt("PIXIV_RANKING_TOP", 20),
+		PixivRankingIncludeTags: parseStringList(os.Getenv("PIXIV_RANKING_INCLUDE_TAGS"), ","),
+		PixivRankingExcludeTags: parseStringList(os.Getenv("PIXIV_RANKING_EXCLUDE_TAGS"), ","),
+		PixivRankingMinWidth:    envInt("PIXIV_RANKING_MIN_WIDTH", 0),
+		PixivRankingMinHeight:   envInt("PIXIV_RANKING_MIN_HEIGHT", 0),
+		PixivRankingOrientation: strings.ToLower(strings.TrimSpace(os.Getenv("PIXIV_RANKING_ORIENTATION"))),
 
 		TwitterAPIDomain:         envOrDefault("TWITTER_API_DOMAIN", "fxtwitter.com"),
 		TwitterAuthorEnabled:     envBool("TWITTER_AUTHOR_ENABLED", false),
@@ -99,19 +183,47 @@ func Load() Config {
 		TwitterAuthorIntervalMin: envInt("TWITTER_AUTHOR_INTERVAL_MINUTES", 60),
 		TwitterAuthorFetchLimit:  envInt("TWITTER_AUTHOR_FETCH_LIMIT", 20),
 
-		GalleryBaselineH: envInt64("GALLERY_BASELINE_H", 0),
-		GalleryBaselineV: envInt64("GALLERY_BASELINE_V", 0),
+		YandeTags:        parseStringList(os.Getenv("YANDE_TAGS"), ";"),
+		YandeIntervalMin: envInt("YANDE_INTERVAL_MINUTES", 60),
+		YandeFetchLimit:  envInt("YANDE_FETCH_LIMIT", 20),
+
+		DanbooruLogin:       strings.TrimSpace(os.Getenv("DANBOORU_LOGIN")),
+		DanbooruAPIKey:      strings.TrimSpace(os.Getenv("DANBOORU_API_KEY")),
+		DanbooruTags:        parseStringList(os.Getenv("DANBOORU_TAGS"), ";"),
+		DanbooruIntervalMin: envInt("DANBOORU_INTERVAL_MINUTES", 60),
+		DanbooruFetchLimit:  envInt("DANBOORU_FETCH_LIMIT", 20),
+
+		IngestWorkers:        envInt("INGEST_WORKERS", 2),
+		IngestJobPollSeconds: envInt("INGEST_JOB_POLL_SECONDS", 30),
+		IngestJobMaxAttempts: envInt("INGEST_JOB_MAX_ATTEMPTS", 6),
+
+		GalleryBaselineH:        envInt64("GALLERY_BASELINE_H", 0),
+		GalleryBaselineV:        envInt64("GALLERY_BASELINE_V", 0),
+		GalleryPHashMaxDistance: envInt("GALLERY_PHASH_MAX_DISTANCE", 5),
+		GalleryVariantWidths:    parseIntList(envOrDefault("GALLERY_VARIANT_WIDTHS", "480,1080")),
 	}
 }
 
+func (c Config) UseSQLite() bool {
+	return strings.EqualFold(c.DBBackend, "sqlite")
+}
+
 func (c Config) HasD1() bool {
 	return c.D1AccountID != "" && c.D1APIToken != "" && c.D1DatabaseID != ""
 }
 
+func (c Config) UseFSStorage() bool {
+	return strings.EqualFold(c.StorageBackend, "fs")
+}
+
 func (c Config) HasR2() bool {
 	return c.R2Endpoint != "" && c.R2Bucket != "" && c.R2AccessKey != "" && c.R2SecretKey != ""
 }
 
+func (c Config) HasAPI() bool {
+	return c.APIToken != ""
+}
+
 func (c Config) HasTelegram() bool {
 	return c.BotToken != ""
 }
@@ -128,6 +240,26 @@ func (c Config) HasPixivCrawler() bool {
 	return c.PixivPHPSESSID != "" && c.PixivUserID != ""
 }
 
+func (c Config) HasPixivArtistCrawler() bool {
+	return len(c.PixivArtistIDs) > 0
+}
+
+func (c Config) HasPixivRankingCrawler() bool {
+	return len(c.PixivRankingModes) > 0
+}
+
+func (c Config) HasPixivFollowCrawler() bool {
+	return c.PixivFollowEnabled && c.PixivPHPSESSID != ""
+}
+
+func (c Config) HasYandeCrawler() bool {
+	return len(c.YandeTags) > 0
+}
+
+func (c Config) HasDanbooruCrawler() bool {
+	return len(c.DanbooruTags) > 0
+}
+
 func (c Config) HasTwitterAuthorCrawler() bool {
 	return c.TwitterAuthorEnabled && len(c.TwitterAuthorUsers) > 0 && len(c.TwitterRSSSources) > 0
 }
@@ -189,7 +321,7 @@ func parseIDSet(raw string) map[int64]struct{} {
 		}
 		id, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			log.Printf("invalid TG_ALLOWED_USER_IDS item %q: %v", v, err)
+			slog.Warn("invalid TG_ALLOWED_USER_IDS item", "item", v, "err", err)
 			continue
 		}
 		out[id] = struct{}{}
@@ -197,6 +329,27 @@ func parseIDSet(raw string) map[int64]struct{} {
 	return out
 }
 
+// parseIntList parses a comma separated list of positive ints; "none" or
+// "0" yields an empty list.
+func parseIntList(raw string) []int {
+	var out []int
+	for _, part := range strings.Split(raw, ",") {
+		v := strings.TrimSpace(part)
+		if v == "" || strings.EqualFold(v, "none") {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			slog.Warn("invalid int list item", "item", v, "err", err)
+			continue
+		}
+		if n > 0 {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
 func parseStringList(raw, sep string) []string {
 	parts := strings.Split(raw, sep)
 	out := make([]string, 0, len(parts))
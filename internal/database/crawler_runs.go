@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// crawlerRunsKeep is how many runs of each crawler are kept; older rows are
+// pruned when a new run is recorded.
+const crawlerRunsKeep = 500
+
+// CrawlerRun is one pass of a crawler. Pages counts listing pages fetched
+// (bookmark pages, feeds, tag queries...); Added/Skipped/Failed count images.
+type CrawlerRun struct {
+	ID         int64
+	Crawler    string
+	StartedAt  int64
+	FinishedAt int64
+	Pages      int
+	Added      int
+	Skipped    int
+	Failed     int
+	Success    bool
+	Error      string
+}
+
+func (c *queries) InsertCrawlerRun(ctx context.Context, run CrawlerRun) error {
+	run.Crawler = strings.TrimSpace(run.Crawler)
+	if run.Crawler == "" {
+		return fmt.Errorf("crawler is required")
+	}
+	status := "failure"
+	if run.Success {
+		status = "success"
+	}
+	if _, err := c.exec(ctx, `
+		INSERT INTO crawler_runs (crawler, started_at, finished_at, pages, added, skipped, failed, status, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.Crawler, run.StartedAt, run.FinishedAt, run.Pages, run.Added, run.Skipped, run.Failed,
+		status, nullIfEmpty(strings.TrimSpace(run.Error)),
+	); err != nil {
+		return err
+	}
+	_, err := c.exec(ctx, `
+		DELETE FROM crawler_runs WHERE crawler = ? AND id <= (
+			SELECT id FROM crawler_runs WHERE crawler = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+		)`, run.Crawler, run.Crawler, crawlerRunsKeep)
+	return err
+}
+
+// LatestCrawlerRuns returns the most recent run of every crawler, ordered
+// by crawler name.
+func (c *queries) LatestCrawlerRuns(ctx context.Context) ([]CrawlerRun, error) {
+	rows, err := c.exec(ctx, `
+		SELECT id, crawler, started_at, finished_at, pages, added, skipped, failed, status, error
+		FROM crawler_runs
+		WHERE id IN (SELECT MAX(id) FROM crawler_runs GROUP BY crawler)
+		ORDER BY crawler`)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]CrawlerRun, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, CrawlerRun{
+			ID:         rowInt64(row, "id"),
+			Crawler:    rowString(row, "crawler"),
+			StartedAt:  rowInt64(row, "started_at"),
+			FinishedAt: rowInt64(row, "finished_at"),
+			Pages:      int(rowInt64(row, "pages")),
+			Added:      int(rowInt64(row, "added")),
+			Skipped:    int(rowInt64(row, "skipped")),
+			Failed:     int(rowInt64(row, "failed")),
+			Success:    rowString(row, "status") == "success",
+			Error:      rowString(row, "error"),
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ingest job states. A job that succeeds is deleted: the gallery row is the
+// record of success, so the table only holds work that is due or given up.
+const (
+	IngestJobPending = "pending"
+	IngestJobDead    = "dead"
+)
+
+// IngestJob is a download that failed inline and is retried by the worker
+// pool. Kind tells the worker how to fetch URL; Payload is the opaque JSON
+// the ingestor needs to rebuild its store input.
+type IngestJob struct {
+	ID        int64
+	Source    string
+	SourceKey string
+	URL       string
+	Kind      string
+	Referer   string
+	Payload   string
+	Status    string
+	Attempts  int
+	NextRunAt int64
+	LastError string
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+type IngestJobCounts struct {
+	Pending int64
+	Dead    int64
+}
+
+const ingestJobColumns = `id, source, source_key, url, kind, referer, payload, status,
+	attempts, next_run_at, last_error, created_at, updated_at`
+
+// EnqueueIngestJob adds a pending job. A source key that already has a job
+// keeps the existing one; the return value reports whether a row was added.
+func (c *queries) EnqueueIngestJob(ctx context.Context, job IngestJob) (bool, error) {
+	job.Source = strings.TrimSpace(job.Source)
+	job.SourceKey = strings.TrimSpace(job.SourceKey)
+	job.URL = strings.TrimSpace(job.URL)
+	if job.Source == "" || job.SourceKey == "" || job.URL == "" {
+		return false, fmt.Errorf("source, source key and url are required")
+	}
+	now := time.Now().Unix()
+	if job.NextRunAt <= 0 {
+		job.NextRunAt = now
+	}
+	rows, err := c.exec(ctx, `
+		INSERT INTO ingest_jobs (source, source_key, url, kind, referer, payload, status,
+			attempts, next_run_at, last_error, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(source_key) DO NOTHING
+		RETURNING id`,
+		job.Source, job.SourceKey, job.URL, strings.TrimSpace(job.Kind), nullIfEmpty(job.Referer),
+		nullIfEmpty(job.Payload), IngestJobPending, job.Attempts, job.NextRunAt,
+		nullIfEmpty(job.LastError), now, now,
+	)
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
+
+// ClaimIngestJobs leases up to limit due jobs: each one gets its attempt
+// counted and next_run_at pushed to leaseUntil in a single statement, so
+// concurrent workers never claim the same job and one that dies mid-run
+// becomes due again once the lease expires.
+func (c *queries) ClaimIngestJobs(ctx context.Context, now, leaseUntil int64, limit int) ([]IngestJob, error) {
+	if limit < 1 {
+		limit = 1
+	}
+	rows, err := c.exec(ctx, `
+		UPDATE ingest_jobs SET attempts = attempts + 1, next_run_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM ingest_jobs
+			WHERE status = ? AND next_run_at <= ?
+			ORDER BY next_run_at, id
+			LIMIT ?
+		) AND status = ? AND next_run_at <= ?
+		RETURNING `+ingestJobColumns,
+		leaseUntil, now, IngestJobPending, now, limit, IngestJobPending, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return rowsIngestJobs(rows), nil
+}
+
+// ReleaseIngestJob hands a claimed job back without running it: the claim's
+// attempt is not counted and the job is due again at nextRunAt.
+func (c *queries) ReleaseIngestJob(ctx context.Context, id, nextRunAt int64) error {
+	_, err := c.exec(ctx,
+		"UPDATE ingest_jobs SET attempts = MAX(attempts - 1, 0), next_run_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		nextRunAt, time.Now().Unix(), id, IngestJobPending,
+	)
+	return err
+}
+
+// CompleteIngestJob removes a job whose item was stored or skipped.
+func (c *queries) CompleteIngestJob(ctx context.Context, id int64) error {
+	_, err := c.exec(ctx, "DELETE FROM ingest_jobs WHERE id = ?", id)
+	return err
+}
+
+// FailIngestJob records a failed attempt. The job is retried at nextRunAt,
+// or moved to the dead-letter state when dead is set.
+func (c *queries) FailIngestJob(ctx context.Context, id int64, lastError string, nextRunAt int64, dead bool) error {
+	status := IngestJobPending
+	if dead {
+		status = IngestJobDead
+	}
+	_, err := c.exec(ctx,
+		"UPDATE ingest_jobs SET status = ?, next_run_at = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		status, nextRunAt, nullIfEmpty(strings.TrimSpace(lastError)), time.Now().Unix(), id,
+	)
+	return err
+}
+
+// RequeueIngestJobs makes dead jobs due now with a fresh attempt budget. An
+// id of 0 requeues every dead job. It returns how many were requeued.
+func (c *queries) RequeueIngestJobs(ctx context.Context, id int64) (int, error) {
+	now := time.Now().Unix()
+	query := "UPDATE ingest_jobs SET status = ?, attempts = 0, next_run_at = ?, updated_at = ? WHERE status = ?"
+	params := []interface{}{IngestJobPending, now, now, IngestJobDead}
+	if id > 0 {
+		query += " AND id = ?"
+		params = append(params, id)
+	}
+	rows, err := c.exec(ctx, query+" RETURNING id", params...)
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// ListIngestJobs returns jobs in status, most recently updated first. An
+// empty status lists every job.
+func (c *queries) ListIngestJobs(ctx context.Context, status string, limit int) ([]IngestJob, error) {
+	if limit < 1 {
+		limit = 20
+	}
+	query := "SELECT " + ingestJobColumns + " FROM ingest_jobs"
+	var params []interface{}
+	if status = strings.TrimSpace(status); status != "" {
+		query += " WHERE status = ?"
+		params = append(params, status)
+	}
+	query += " ORDER BY updated_at DESC, id DESC LIMIT ?"
+	params = append(params, limit)
+	rows, err := c.exec(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	return rowsIngestJobs(rows), nil
+}
+
+func (c *queries) CountIngestJobs(ctx context.Context) (IngestJobCounts, error) {
+	rows, err := c.exec(ctx, "SELECT status, COUNT(*) AS c FROM ingest_jobs GROUP BY status")
+	if err != nil {
+		return IngestJobCounts{}, err
+	}
+	var counts IngestJobCounts
+	for _, row := range rows {
+		switch rowString(row, "status") {
+		case IngestJobPending:
+			counts.Pending = rowInt64(row, "c")
+		case IngestJobDead:
+			counts.Dead = rowInt64(row, "c")
+		}
+	}
+	return counts, nil
+}
+
+func rowsIngestJobs(rows []map[string]interface{}) []IngestJob {
+	out := make([]IngestJob, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, IngestJob{
+			ID:        rowInt64(row, "id"),
+			Source:    rowString(row, "source"),
+			SourceKey: rowString(row, "source_key"),
+			URL:       rowString(row, "url"),
+			Kind:      rowString(row, "kind"),
+			Referer:   rowString(row, "referer"),
+			Payload:   rowString(row, "payload"),
+			Status:    rowString(row, "status"),
+			Attempts:  int(rowInt64(row, "attempts")),
+			NextRunAt: rowInt64(row, "next_run_at"),
+			LastError: rowString(row, "last_error"),
+			CreatedAt: rowInt64(row, "created_at"),
+			UpdatedAt: rowInt64(row, "updated_at"),
+		})
+	}
+	return out
+}
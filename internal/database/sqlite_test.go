@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSQLite(t *testing.T) *SQLiteClient {
+	t.Helper()
+	db, err := NewSQLite(filepath.Join(t.TempDir(), "gallery.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.EnsureSchema(context.Background()); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+	return db
+}
+
+func TestSQLiteGalleryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+
+	if applied, err := db.EnsureGallerySeqBaselineIfEmpty(ctx, 10, 0); err != nil || !applied {
+		t.Fatalf("EnsureGallerySeqBaselineIfEmpty = %v, %v; want true, nil", applied, err)
+	}
+	seq, err := db.NextGallerySeq(ctx, "h")
+	if err != nil {
+		t.Fatalf("NextGallerySeq: %v", err)
+	}
+	if seq != 11 {
+		t.Fatalf("next h seq = %d, want 11", seq)
+	}
+
+	err = db.InsertGalleryImage(ctx, GalleryImage{
+		Source:      "tg",
+		SourceKey:   "tgfile_abc",
+		SHA256:      "ABCDEF",
+		Orientation: "h",
+		Seq:         seq,
+		R2Key:       "ri/h/11.webp",
+		Width:       1920,
+		Height:      1080,
+	})
+	if err != nil {
+		t.Fatalf("InsertGalleryImage: %v", err)
+	}
+
+	if ok, err := db.ExistsGallerySourceKey(ctx, "tgfile_abc"); err != nil || !ok {
+		t.Fatalf("ExistsGallerySourceKey = %v, %v; want true, nil", ok, err)
+	}
+	if ok, err := db.ExistsGallerySHA256(ctx, "abcdef"); err != nil || !ok {
+		t.Fatalf("ExistsGallerySHA256 = %v, %v; want true, nil", ok, err)
+	}
+	if seq, _ := db.NextGallerySeq(ctx, "h"); seq != 12 {
+		t.Fatalf("next h seq after insert = %d, want 12", seq)
+	}
+	counts, err := db.CountGalleryActive(ctx)
+	if err != nil {
+		t.Fatalf("CountGalleryActive: %v", err)
+	}
+	if counts.H != 1 || counts.V != 0 {
+		t.Fatalf("counts = %+v, want h=1 v=0", counts)
+	}
+}
+
+func TestSQLiteCrawlerState(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+
+	if _, ok, err := db.GetCrawlerState(ctx, "pixiv_bootstrap_done"); err != nil || ok {
+		t.Fatalf("GetCrawlerState on empty db = %v, %v; want false, nil", ok, err)
+	}
+	if err := db.SetCrawlerState(ctx, "pixiv_bootstrap_done", "1"); err != nil {
+		t.Fatalf("SetCrawlerState: %v", err)
+	}
+	if err := db.SetCrawlerState(ctx, "pixiv_bootstrap_done", "2"); err != nil {
+		t.Fatalf("SetCrawlerState overwrite: %v", err)
+	}
+	v, ok, err := db.GetCrawlerState(ctx, "pixiv_bootstrap_done")
+	if err != nil || !ok || v != "2" {
+		t.Fatalf("GetCrawlerState = %q, %v, %v; want \"2\", true, nil", v, ok, err)
+	}
+}
+
+func TestSQLiteAllocateGallerySeq(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+
+	if err := db.SetGallerySeqBaseline(ctx, "v", 5); err != nil {
+		t.Fatalf("SetGallerySeqBaseline: %v", err)
+	}
+	first, err := db.AllocateGallerySeq(ctx, "v")
+	if err != nil || first != 6 {
+		t.Fatalf("first AllocateGallerySeq = %d, %v; want 6, nil", first, err)
+	}
+	second, err := db.AllocateGallerySeq(ctx, "v")
+	if err != nil || second != 7 {
+		t.Fatalf("second AllocateGallerySeq = %d, %v; want 7, nil", second, err)
+	}
+
+	// Releasing an older seq must not rewind past a newer allocation.
+	if err := db.ReleaseGallerySeq(ctx, "v", first); err != nil {
+		t.Fatalf("ReleaseGallerySeq(first): %v", err)
+	}
+	if err := db.ReleaseGallerySeq(ctx, "v", second); err != nil {
+		t.Fatalf("ReleaseGallerySeq(second): %v", err)
+	}
+	if next, _ := db.AllocateGallerySeq(ctx, "v"); next != 7 {
+		t.Fatalf("AllocateGallerySeq after release = %d, want 7", next)
+	}
+
+	err = db.InsertGalleryImage(ctx, GalleryImage{SourceKey: "a", SHA256: "aa", Orientation: "v", Seq: 7, R2Key: "ri/v/7.webp"})
+	if err != nil {
+		t.Fatalf("InsertGalleryImage: %v", err)
+	}
+	err = db.InsertGalleryImage(ctx, GalleryImage{SourceKey: "b", SHA256: "bb", Orientation: "v", Seq: 7, R2Key: "ri/v/7.webp"})
+	if !errors.Is(err, ErrSeqTaken) {
+		t.Fatalf("duplicate seq insert err = %v, want ErrSeqTaken", err)
+	}
+	err = db.InsertGalleryImage(ctx, GalleryImage{SourceKey: "a", SHA256: "cc", Orientation: "v", Seq: 8, R2Key: "ri/v/8.webp"})
+	if !errors.Is(err, ErrDuplicateSource) {
+		t.Fatalf("duplicate source insert err = %v, want ErrDuplicateSource", err)
+	}
+}
+
+func TestSQLiteGalleryImageCredits(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+
+	for i, img := range []GalleryImage{
+		{SourceKey: "pixiv_1_p0", SHA256: "aa", Seq: 1, Title: "Ganyu", AuthorName: "artist", AuthorID: "42"},
+		{SourceKey: "tgfile_x", SHA256: "bb", Seq: 2},
+	} {
+		img.Source = "test"
+		img.Orientation = "h"
+		img.R2Key = fmt.Sprintf("ri/h/%d.webp", img.Seq)
+		if err := db.InsertGalleryImage(ctx, img); err != nil {
+			t.Fatalf("InsertGalleryImage #%d: %v", i, err)
+		}
+	}
+
+	got, ok, err := db.GetGalleryImage(ctx, "h", 1)
+	if err != nil || !ok {
+		t.Fatalf("GetGalleryImage = %v, %v", ok, err)
+	}
+	if got.Title != "Ganyu" || got.AuthorName != "artist" || got.AuthorID != "42" {
+		t.Fatalf("credits = %q %q %q", got.Title, got.AuthorName, got.AuthorID)
+	}
+	if legacy, _, _ := db.GetGalleryImage(ctx, "h", 2); legacy.Title != "" || legacy.AuthorName != "" {
+		t.Fatalf("row without credits = %+v", legacy)
+	}
+
+	if err := db.AddGalleryImageTags(ctx, "pixiv_1_p0", []string{"Genshin  Impact", "ganyu", "genshin impact", " "}); err != nil {
+		t.Fatalf("AddGalleryImageTags: %v", err)
+	}
+	if err := db.AddGalleryImageTags(ctx, "tgfile_x", []string{"ganyu"}); err != nil {
+		t.Fatalf("AddGalleryImageTags second image: %v", err)
+	}
+	tags, err := db.ListGalleryImageTags(ctx, "pixiv_1_p0")
+	if err != nil {
+		t.Fatalf("ListGalleryImageTags: %v", err)
+	}
+	if len(tags) != 2 || tags[0] != "ganyu" || tags[1] != "genshin impact" {
+		t.Fatalf("tags = %v, want [ganyu genshin impact]", tags)
+	}
+	rows, err := db.exec(ctx, "SELECT COUNT(*) AS c FROM tags")
+	if err != nil || rowInt64(rows[0], "c") != 2 {
+		t.Fatalf("tags table rows = %v, %v; want 2 shared rows", rows, err)
+	}
+}
+
+func TestSQLiteIngestJobLifecycle(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+
+	job := IngestJob{Source: "pixiv", SourceKey: "pixiv_1_p0", URL: "https://i.pximg.net/1.png", Kind: "pixiv", Attempts: 1, NextRunAt: 100, LastError: "status 502"}
+	if added, err := db.EnqueueIngestJob(ctx, job); err != nil || !added {
+		t.Fatalf("EnqueueIngestJob = %v, %v; want true, nil", added, err)
+	}
+	if added, err := db.EnqueueIngestJob(ctx, job); err != nil || added {
+		t.Fatalf("EnqueueIngestJob duplicate = %v, %v; want false, nil", added, err)
+	}
+
+	if jobs, err := db.ClaimIngestJobs(ctx, 99, 1000, 5); err != nil || len(jobs) != 0 {
+		t.Fatalf("ClaimIngestJobs before due = %v, %v; want none", jobs, err)
+	}
+	jobs, err := db.ClaimIngestJobs(ctx, 100, 1000, 5)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("ClaimIngestJobs = %v, %v; want one job", jobs, err)
+	}
+	if got := jobs[0]; got.Attempts != 2 || got.NextRunAt != 1000 || got.Kind != "pixiv" || got.LastError != "status 502" {
+		t.Fatalf("claimed job = %+v", got)
+	}
+	if again, _ := db.ClaimIngestJobs(ctx, 100, 1000, 5); len(again) != 0 {
+		t.Fatalf("leased job claimed twice: %+v", again)
+	}
+
+	id := jobs[0].ID
+	if err := db.ReleaseIngestJob(ctx, id, 1500); err != nil {
+		t.Fatalf("ReleaseIngestJob: %v", err)
+	}
+	if jobs, _ := db.ClaimIngestJobs(ctx, 1400, 1600, 5); len(jobs) != 0 {
+		t.Fatalf("released job claimed before it is due: %+v", jobs)
+	}
+	if jobs, err := db.ClaimIngestJobs(ctx, 1500, 1600, 5); err != nil || len(jobs) != 1 || jobs[0].Attempts != 2 {
+		t.Fatalf("claim after release = %+v, %v; want the attempt not counted", jobs, err)
+	}
+	if err := db.FailIngestJob(ctx, id, "status 404", 2000, true); err != nil {
+		t.Fatalf("FailIngestJob: %v", err)
+	}
+	if counts, err := db.CountIngestJobs(ctx); err != nil || counts.Dead != 1 || counts.Pending != 0 {
+		t.Fatalf("CountIngestJobs = %+v, %v; want 1 dead", counts, err)
+	}
+	if jobs, _ := db.ClaimIngestJobs(ctx, 5000, 6000, 5); len(jobs) != 0 {
+		t.Fatalf("dead job claimed: %+v", jobs)
+	}
+	dead, err := db.ListIngestJobs(ctx, IngestJobDead, 10)
+	if err != nil || len(dead) != 1 || dead[0].LastError != "status 404" {
+		t.Fatalf("ListIngestJobs dead = %+v, %v", dead, err)
+	}
+
+	if n, err := db.RequeueIngestJobs(ctx, 0); err != nil || n != 1 {
+		t.Fatalf("RequeueIngestJobs = %d, %v; want 1", n, err)
+	}
+	if n, _ := db.RequeueIngestJobs(ctx, id); n != 0 {
+		t.Fatalf("requeued a pending job: %d", n)
+	}
+	jobs, err = db.ClaimIngestJobs(ctx, 1<<40, 1<<41, 5)
+	if err != nil || len(jobs) != 1 || jobs[0].Attempts != 1 {
+		t.Fatalf("claim after requeue = %+v, %v; want attempts reset", jobs, err)
+	}
+	if err := db.CompleteIngestJob(ctx, id); err != nil {
+		t.Fatalf("CompleteIngestJob: %v", err)
+	}
+	if counts, _ := db.CountIngestJobs(ctx); counts != (IngestJobCounts{}) {
+		t.Fatalf("counts after complete = %+v", counts)
+	}
+}
@@ -0,0 +1,880 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store is the metadata backend shared by the gallery pipeline, the TG
+// handlers and the crawlers. Both the D1 client and the SQLite client
+// implement it with the same SQL.
+type Store interface {
+	EnsureSchema(ctx context.Context) error
+	EnsureGallerySeqBaselineIfEmpty(ctx context.Context, hLastSeq, vLastSeq int64) (bool, error)
+
+	IsBlocked(ctx context.Context, key string) (bool, error)
+	ExistsGallerySourceKey(ctx context.Context, sourceKey string) (bool, error)
+	ExistsGallerySHA256(ctx context.Context, sha256 string) (bool, error)
+	NextGallerySeq(ctx context.Context, orientation string) (int64, error)
+	AllocateGallerySeq(ctx context.Context, orientation string) (int64, error)
+	ReleaseGallerySeq(ctx context.Context, orientation string, seq int64) error
+	InsertGalleryImage(ctx context.Context, img GalleryImage) error
+	CountGalleryActive(ctx context.Context) (GalleryCounts, error)
+	GetGalleryImage(ctx context.Context, orientation string, seq int64) (GalleryImage, bool, error)
+	LastGalleryImage(ctx context.Context, orientation string) (GalleryImage, bool, error)
+	GetGalleryImageByID(ctx context.Context, id string) (GalleryImage, bool, error)
+	ListGalleryPHashes(ctx context.Context, afterRowID int64) ([]GalleryPHash, error)
+	ListActiveGalleryImages(ctx context.Context, orientation string) ([]GalleryImage, error)
+	ListGalleryImages(ctx context.Context, f GalleryImageFilter) ([]GalleryImage, error)
+	MarkGalleryImageRemoved(ctx context.Context, id string) error
+	MoveGalleryImage(ctx context.Context, id string, seq int64, r2Key string) error
+	RestoreGalleryImage(ctx context.Context, id string, seq int64, r2Key string) error
+	AddBlock(ctx context.Context, key, reason string) error
+
+	UpsertGalleryVariant(ctx context.Context, v GalleryVariant) error
+	ListGalleryVariants(ctx context.Context, orientation string, seq int64) ([]GalleryVariant, error)
+	ListGalleryVariantSeqs(ctx context.Context, orientation string, width int) ([]int64, error)
+	MoveGalleryVariants(ctx context.Context, orientation string, fromSeq, toSeq int64) error
+	DeleteGalleryVariants(ctx context.Context, orientation string, seq int64) error
+	CountGalleryVariants(ctx context.Context) ([]GalleryVariantCount, error)
+
+	AddGalleryImageTags(ctx context.Context, imageID string, tags []string) error
+	ListGalleryImageTags(ctx context.Context, imageID string) ([]string, error)
+
+	GetCrawlerState(ctx context.Context, key string) (string, bool, error)
+	SetCrawlerState(ctx context.Context, key, value string) error
+
+	EnqueueIngestJob(ctx context.Context, job IngestJob) (bool, error)
+	ClaimIngestJobs(ctx context.Context, now, leaseUntil int64, limit int) ([]IngestJob, error)
+	ReleaseIngestJob(ctx context.Context, id, nextRunAt int64) error
+	CompleteIngestJob(ctx context.Context, id int64) error
+	FailIngestJob(ctx context.Context, id int64, lastError string, nextRunAt int64, dead bool) error
+	RequeueIngestJobs(ctx context.Context, id int64) (int, error)
+	ListIngestJobs(ctx context.Context, status string, limit int) ([]IngestJob, error)
+	CountIngestJobs(ctx context.Context) (IngestJobCounts, error)
+
+	InsertCrawlerRun(ctx context.Context, run CrawlerRun) error
+	LatestCrawlerRuns(ctx context.Context) ([]CrawlerRun, error)
+}
+
+var (
+	_ Store = (*Client)(nil)
+	_ Store = (*SQLiteClient)(nil)
+)
+
+// Unique-constraint failures from InsertGalleryImage are wrapped in one of
+// these so callers can tell a lost race from a real error.
+var (
+	ErrDuplicateSource = errors.New("gallery image source already exists")
+	ErrDuplicateHash   = errors.New("gallery image sha256 already exists")
+	ErrSeqTaken        = errors.New("gallery seq already taken")
+)
+
+type GalleryImage struct {
+	ID           string
+	Source       string
+	SourceKey    string
+	SourceURL    string
+	SourcePostID string
+	SHA256       string
+	PHash        string // hex dHash; empty for rows stored before phash existed
+	// Title and author come from the source post; empty (NULL) for rows
+	// stored before they were recorded and for sources without them.
+	Title       string
+	AuthorName  string
+	AuthorID    string
+	Orientation string // h / v
+	Seq         int64
+	R2Key       string
+	Width       int
+	Height      int
+	Bytes       int64
+	MimeType    string
+	PublishedAt int64
+	CollectedAt int64
+	Status      string
+}
+
+// GalleryImageFilter narrows ListGalleryImages. Empty strings and zero
+// times match everything. Results are ordered newest collected first;
+// AfterCollectedAt/AfterID is the keyset cursor of the last row seen.
+type GalleryImageFilter struct {
+	Source        string
+	Orientation   string
+	Status        string
+	CollectedFrom int64 // inclusive, unix seconds
+	CollectedTo   int64 // exclusive, unix seconds
+
+	AfterCollectedAt int64
+	AfterID          string
+	Limit            int
+}
+
+type GalleryCounts struct {
+	H int64 `json:"h"`
+	V int64 `json:"v"`
+}
+
+type execFunc func(ctx context.Context, sql string, params ...interface{}) ([]map[string]interface{}, error)
+
+// queries holds the SQL shared by every backend. Backends only provide exec,
+// which runs one statement and returns its rows as column->value maps.
+type queries struct {
+	exec execFunc
+}
+
+func (c *queries) EnsureSchema(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS gallery_images (
+			id TEXT PRIMARY KEY,
+			source TEXT NOT NULL,
+			source_key TEXT NOT NULL UNIQUE,
+			source_url TEXT,
+			source_post_id TEXT,
+			sha256 TEXT NOT NULL UNIQUE,
+			orientation TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			r2_key TEXT NOT NULL UNIQUE,
+			width INTEGER NOT NULL,
+			height INTEGER NOT NULL,
+			bytes INTEGER NOT NULL DEFAULT 0,
+			mime_type TEXT NOT NULL DEFAULT 'image/webp',
+			published_at INTEGER NOT NULL DEFAULT 0,
+			collected_at INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'active'
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_gallery_images_orientation_seq
+			ON gallery_images(orientation, seq)`,
+		`CREATE INDEX IF NOT EXISTS idx_gallery_images_status_orientation_seq
+			ON gallery_images(status, orientation, seq)`,
+		`CREATE INDEX IF NOT EXISTS idx_gallery_images_collected_at
+			ON gallery_images(collected_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_gallery_images_source
+			ON gallery_images(source, source_post_id)`,
+		`CREATE TABLE IF NOT EXISTS ingest_blocklist (
+			block_key TEXT PRIMARY KEY,
+			reason TEXT,
+			created_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS gallery_seq_counters (
+			orientation TEXT PRIMARY KEY,
+			last_seq INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS gallery_variants (
+			orientation TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			width INTEGER NOT NULL,
+			height INTEGER NOT NULL,
+			bytes INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (orientation, seq, width)
+		)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE
+		)`,
+		`CREATE TABLE IF NOT EXISTS gallery_image_tags (
+			image_id TEXT NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (image_id, tag_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_gallery_image_tags_tag
+			ON gallery_image_tags(tag_id)`,
+		`CREATE TABLE IF NOT EXISTS crawler_state (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS ingest_jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT NOT NULL,
+			source_key TEXT NOT NULL UNIQUE,
+			url TEXT NOT NULL,
+			kind TEXT NOT NULL DEFAULT 'http',
+			referer TEXT,
+			payload TEXT,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_run_at INTEGER NOT NULL,
+			last_error TEXT,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_ingest_jobs_status_next_run
+			ON ingest_jobs(status, next_run_at)`,
+		`CREATE TABLE IF NOT EXISTS crawler_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			crawler TEXT NOT NULL,
+			started_at INTEGER NOT NULL,
+			finished_at INTEGER NOT NULL,
+			pages INTEGER NOT NULL DEFAULT 0,
+			added INTEGER NOT NULL DEFAULT 0,
+			skipped INTEGER NOT NULL DEFAULT 0,
+			failed INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL,
+			error TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_crawler_runs_crawler
+			ON crawler_runs(crawler, id)`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := c.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	// Columns added after the first release. SQLite has no ADD COLUMN IF NOT
+	// EXISTS, so a duplicate-column error just means it is already there.
+	columns := []string{
+		`ALTER TABLE gallery_images ADD COLUMN phash TEXT`,
+		`ALTER TABLE gallery_images ADD COLUMN title TEXT`,
+		`ALTER TABLE gallery_images ADD COLUMN author_name TEXT`,
+		`ALTER TABLE gallery_images ADD COLUMN author_id TEXT`,
+	}
+	for _, stmt := range columns {
+		if _, err := c.exec(ctx, stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *queries) IsBlocked(ctx context.Context, key string) (bool, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return false, nil
+	}
+	rows, err := c.exec(ctx, "SELECT 1 FROM ingest_blocklist WHERE block_key = ? LIMIT 1", key)
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
+
+func (c *queries) ExistsGallerySourceKey(ctx context.Context, sourceKey string) (bool, error) {
+	sourceKey = strings.TrimSpace(sourceKey)
+	if sourceKey == "" {
+		return false, nil
+	}
+	rows, err := c.exec(ctx, "SELECT 1 FROM gallery_images WHERE source_key = ? LIMIT 1", sourceKey)
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
+
+func (c *queries) ExistsGallerySHA256(ctx context.Context, sha256 string) (bool, error) {
+	sha256 = strings.ToLower(strings.TrimSpace(sha256))
+	if sha256 == "" {
+		return false, nil
+	}
+	rows, err := c.exec(ctx, "SELECT 1 FROM gallery_images WHERE sha256 = ? LIMIT 1", sha256)
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
+
+func (c *queries) GetCrawlerState(ctx context.Context, key string) (string, bool, error) {
+	rows, err := c.exec(ctx, "SELECT value FROM crawler_state WHERE key = ? LIMIT 1", key)
+	if err != nil {
+		return "", false, err
+	}
+	if len(rows) == 0 {
+		return "", false, nil
+	}
+	return rowString(rows[0], "value"), true, nil
+}
+
+func (c *queries) SetCrawlerState(ctx context.Context, key, value string) error {
+	_, err := c.exec(ctx,
+		"INSERT OR REPLACE INTO crawler_state (key, value, updated_at) VALUES (?, ?, ?)",
+		strings.TrimSpace(key), strings.TrimSpace(value), time.Now().Unix(),
+	)
+	return err
+}
+
+func (c *queries) NextGallerySeq(ctx context.Context, orientation string) (int64, error) {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return 0, fmt.Errorf("invalid orientation")
+	}
+
+	baseline, err := c.GetGallerySeqBaseline(ctx, orientation)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := c.exec(ctx,
+		"SELECT COALESCE(MAX(seq), 0) + 1 AS next_seq FROM gallery_images WHERE orientation = ?",
+		orientation,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 1, nil
+	}
+	next := rowInt64(rows[0], "next_seq")
+	if next < 1 {
+		next = 1
+	}
+	if baseline > 0 && next < baseline+1 {
+		next = baseline + 1
+	}
+	return next, nil
+}
+
+// AllocateGallerySeq hands out the next seq for an orientation with a single
+// upsert on gallery_seq_counters, so concurrent instances never receive the
+// same number. The counter is floored at MAX(seq) and the configured baseline,
+// which also seeds it on first use.
+func (c *queries) AllocateGallerySeq(ctx context.Context, orientation string) (int64, error) {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return 0, fmt.Errorf("invalid orientation")
+	}
+	rows, err := c.exec(ctx, `
+		INSERT INTO gallery_seq_counters (orientation, last_seq, updated_at)
+		VALUES (?, MAX(
+			COALESCE((SELECT MAX(seq) FROM gallery_images WHERE orientation = ?), 0),
+			COALESCE((SELECT CAST(value AS INTEGER) FROM crawler_state WHERE key = ?), 0)
+		) + 1, ?)
+		ON CONFLICT(orientation) DO UPDATE SET
+			last_seq = MAX(gallery_seq_counters.last_seq + 1, excluded.last_seq),
+			updated_at = excluded.updated_at
+		RETURNING last_seq`,
+		orientation, orientation, gallerySeqBaselineKey(orientation), time.Now().Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("allocate seq: no row returned")
+	}
+	seq := rowInt64(rows[0], "last_seq")
+	if seq < 1 {
+		return 0, fmt.Errorf("allocate seq: invalid seq %d", seq)
+	}
+	return seq, nil
+}
+
+// ReleaseGallerySeq gives back an allocated seq that was never used. It only
+// rewinds the counter if nobody allocated after us, so it cannot hand out a
+// seq twice; otherwise the gap is left as is.
+func (c *queries) ReleaseGallerySeq(ctx context.Context, orientation string, seq int64) error {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return fmt.Errorf("invalid orientation")
+	}
+	_, err := c.exec(ctx,
+		"UPDATE gallery_seq_counters SET last_seq = last_seq - 1, updated_at = ? WHERE orientation = ? AND last_seq = ?",
+		time.Now().Unix(), orientation, seq,
+	)
+	return err
+}
+
+func (c *queries) EnsureGallerySeqBaselineIfEmpty(ctx context.Context, hLastSeq, vLastSeq int64) (bool, error) {
+	if hLastSeq < 0 {
+		hLastSeq = 0
+	}
+	if vLastSeq < 0 {
+		vLastSeq = 0
+	}
+	if hLastSeq == 0 && vLastSeq == 0 {
+		return false, nil
+	}
+
+	rows, err := c.exec(ctx, "SELECT 1 FROM gallery_images LIMIT 1")
+	if err != nil {
+		return false, err
+	}
+	if len(rows) > 0 {
+		return false, nil
+	}
+
+	marker, ok, err := c.GetCrawlerState(ctx, "gallery_seq_baseline_initialized")
+	if err != nil {
+		return false, err
+	}
+	if ok && strings.TrimSpace(marker) != "" {
+		return false, nil
+	}
+
+	if hLastSeq > 0 {
+		if err := c.SetGallerySeqBaseline(ctx, "h", hLastSeq); err != nil {
+			return false, err
+		}
+	}
+	if vLastSeq > 0 {
+		if err := c.SetGallerySeqBaseline(ctx, "v", vLastSeq); err != nil {
+			return false, err
+		}
+	}
+	if err := c.SetCrawlerState(ctx, "gallery_seq_baseline_initialized", fmt.Sprintf("h=%d,v=%d", hLastSeq, vLastSeq)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *queries) GetGallerySeqBaseline(ctx context.Context, orientation string) (int64, error) {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return 0, fmt.Errorf("invalid orientation")
+	}
+	key := gallerySeqBaselineKey(orientation)
+	v, ok, err := c.GetCrawlerState(ctx, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || n < 0 {
+		return 0, nil
+	}
+	return n, nil
+}
+
+func (c *queries) SetGallerySeqBaseline(ctx context.Context, orientation string, lastSeq int64) error {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return fmt.Errorf("invalid orientation")
+	}
+	if lastSeq < 0 {
+		return fmt.Errorf("baseline seq must be >= 0")
+	}
+	return c.SetCrawlerState(ctx, gallerySeqBaselineKey(orientation), strconv.FormatInt(lastSeq, 10))
+}
+
+func (c *queries) InsertGalleryImage(ctx context.Context, img GalleryImage) error {
+	img.Source = strings.TrimSpace(img.Source)
+	img.SourceKey = strings.TrimSpace(img.SourceKey)
+	img.SourceURL = strings.TrimSpace(img.SourceURL)
+	img.SourcePostID = strings.TrimSpace(img.SourcePostID)
+	img.SHA256 = strings.ToLower(strings.TrimSpace(img.SHA256))
+	img.PHash = strings.ToLower(strings.TrimSpace(img.PHash))
+	img.Title = strings.TrimSpace(img.Title)
+	img.AuthorName = strings.TrimSpace(img.AuthorName)
+	img.AuthorID = strings.TrimSpace(img.AuthorID)
+	img.Orientation = normalizeOrientation(img.Orientation)
+	img.R2Key = strings.TrimSpace(img.R2Key)
+	img.MimeType = strings.TrimSpace(img.MimeType)
+	img.Status = strings.TrimSpace(img.Status)
+
+	if img.ID == "" {
+		img.ID = strings.TrimSpace(img.SourceKey)
+	}
+	if img.Orientation == "" {
+		return fmt.Errorf("invalid orientation")
+	}
+	if img.SourceKey == "" {
+		return fmt.Errorf("source_key is required")
+	}
+	if img.SHA256 == "" {
+		return fmt.Errorf("sha256 is required")
+	}
+	if img.Seq < 1 {
+		return fmt.Errorf("seq must be >= 1")
+	}
+	if img.R2Key == "" {
+		return fmt.Errorf("r2_key is required")
+	}
+	if img.MimeType == "" {
+		img.MimeType = "image/webp"
+	}
+	if img.CollectedAt <= 0 {
+		img.CollectedAt = time.Now().Unix()
+	}
+	if img.Status == "" {
+		img.Status = "active"
+	}
+
+	sql := `INSERT INTO gallery_images (
+		id, source, source_key, source_url, source_post_id,
+		sha256, phash, orientation, seq, r2_key,
+		width, height, bytes, mime_type,
+		published_at, collected_at, status,
+		title, author_name, author_id
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := c.exec(ctx, sql,
+		img.ID,
+		img.Source,
+		img.SourceKey,
+		img.SourceURL,
+		img.SourcePostID,
+		img.SHA256,
+		nullIfEmpty(img.PHash),
+		img.Orientation,
+		img.Seq,
+		img.R2Key,
+		img.Width,
+		img.Height,
+		img.Bytes,
+		img.MimeType,
+		img.PublishedAt,
+		img.CollectedAt,
+		img.Status,
+		nullIfEmpty(img.Title),
+		nullIfEmpty(img.AuthorName),
+		nullIfEmpty(img.AuthorID),
+	)
+	return classifyInsertErr(err)
+}
+
+func (c *queries) CountGalleryActive(ctx context.Context) (GalleryCounts, error) {
+	rows, err := c.exec(ctx, `
+		SELECT orientation, COUNT(*) AS c
+		FROM gallery_images
+		WHERE status = 'active'
+		GROUP BY orientation
+	`)
+	if err != nil {
+		return GalleryCounts{}, err
+	}
+
+	var counts GalleryCounts
+	for _, row := range rows {
+		switch normalizeOrientation(rowString(row, "orientation")) {
+		case "h":
+			counts.H = rowInt64(row, "c")
+		case "v":
+			counts.V = rowInt64(row, "c")
+		}
+	}
+	return counts, nil
+}
+
+const galleryImageColumns = `id, source, source_key, source_url, source_post_id,
+	sha256, phash, orientation, seq, r2_key,
+	width, height, bytes, mime_type,
+	published_at, collected_at, status,
+	title, author_name, author_id`
+
+func (c *queries) GetGalleryImage(ctx context.Context, orientation string, seq int64) (GalleryImage, bool, error) {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return GalleryImage{}, false, fmt.Errorf("invalid orientation")
+	}
+	rows, err := c.exec(ctx,
+		"SELECT "+galleryImageColumns+" FROM gallery_images WHERE orientation = ? AND seq = ? LIMIT 1",
+		orientation, seq,
+	)
+	if err != nil || len(rows) == 0 {
+		return GalleryImage{}, false, err
+	}
+	return rowGalleryImage(rows[0]), true, nil
+}
+
+// GetGalleryImageByID returns a row whatever its status.
+func (c *queries) GetGalleryImageByID(ctx context.Context, id string) (GalleryImage, bool, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return GalleryImage{}, false, fmt.Errorf("id is required")
+	}
+	rows, err := c.exec(ctx, "SELECT "+galleryImageColumns+" FROM gallery_images WHERE id = ?", id)
+	if err != nil || len(rows) == 0 {
+		return GalleryImage{}, false, err
+	}
+	return rowGalleryImage(rows[0]), true, nil
+}
+
+// LastGalleryImage returns the active image holding the highest seq of an
+// orientation.
+func (c *queries) LastGalleryImage(ctx context.Context, orientation string) (GalleryImage, bool, error) {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return GalleryImage{}, false, fmt.Errorf("invalid orientation")
+	}
+	rows, err := c.exec(ctx,
+		"SELECT "+galleryImageColumns+" FROM gallery_images WHERE orientation = ? AND status = 'active' ORDER BY seq DESC LIMIT 1",
+		orientation,
+	)
+	if err != nil || len(rows) == 0 {
+		return GalleryImage{}, false, err
+	}
+	return rowGalleryImage(rows[0]), true, nil
+}
+
+// ListActiveGalleryImages returns the active images of an orientation
+// ordered by seq.
+func (c *queries) ListActiveGalleryImages(ctx context.Context, orientation string) ([]GalleryImage, error) {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return nil, fmt.Errorf("invalid orientation")
+	}
+	rows, err := c.exec(ctx,
+		"SELECT "+galleryImageColumns+" FROM gallery_images WHERE orientation = ? AND status = 'active' ORDER BY seq",
+		orientation,
+	)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GalleryImage, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, rowGalleryImage(row))
+	}
+	return out, nil
+}
+
+func (c *queries) ListGalleryImages(ctx context.Context, f GalleryImageFilter) ([]GalleryImage, error) {
+	var (
+		where  []string
+		params []interface{}
+	)
+	if v := strings.TrimSpace(f.Source); v != "" {
+		where = append(where, "source = ?")
+		params = append(params, v)
+	}
+	if v := strings.TrimSpace(f.Orientation); v != "" {
+		if v = normalizeOrientation(v); v == "" {
+			return nil, fmt.Errorf("invalid orientation")
+		}
+		where = append(where, "orientation = ?")
+		params = append(params, v)
+	}
+	if v := strings.TrimSpace(f.Status); v != "" {
+		where = append(where, "status = ?")
+		params = append(params, v)
+	}
+	if f.CollectedFrom > 0 {
+		where = append(where, "collected_at >= ?")
+		params = append(params, f.CollectedFrom)
+	}
+	if f.CollectedTo > 0 {
+		where = append(where, "collected_at < ?")
+		params = append(params, f.CollectedTo)
+	}
+	if f.AfterID != "" {
+		where = append(where, "(collected_at < ? OR (collected_at = ? AND id < ?))")
+		params = append(params, f.AfterCollectedAt, f.AfterCollectedAt, f.AfterID)
+	}
+	if f.Limit < 1 {
+		f.Limit = 50
+	}
+	sql := "SELECT " + galleryImageColumns + " FROM gallery_images"
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += " ORDER BY collected_at DESC, id DESC LIMIT ?"
+	params = append(params, f.Limit)
+
+	rows, err := c.exec(ctx, sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GalleryImage, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, rowGalleryImage(row))
+	}
+	return out, nil
+}
+
+// GalleryPHash is the phash of one gallery_images row. RowID only grows, so
+// callers can fetch the rows added since their last read.
+type GalleryPHash struct {
+	RowID int64
+	ID    string
+	PHash string
+}
+
+// ListGalleryPHashes returns the rows after afterRowID that have a phash,
+// removed ones included, so taken-down artwork is also caught when
+// re-posted.
+func (c *queries) ListGalleryPHashes(ctx context.Context, afterRowID int64) ([]GalleryPHash, error) {
+	rows, err := c.exec(ctx, `
+		SELECT rowid AS row_id, id, phash
+		FROM gallery_images
+		WHERE rowid > ? AND phash IS NOT NULL AND phash != ''
+		ORDER BY rowid
+	`, afterRowID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GalleryPHash, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, GalleryPHash{
+			RowID: rowInt64(row, "row_id"),
+			ID:    rowString(row, "id"),
+			PHash: rowString(row, "phash"),
+		})
+	}
+	return out, nil
+}
+
+// MarkGalleryImageRemoved soft-deletes a row. The row keeps its sha256 and
+// source_key for dedupe, but gives up its seq and r2_key (seq becomes
+// -rowid) so another image can be moved into the slot.
+func (c *queries) MarkGalleryImageRemoved(ctx context.Context, id string) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	_, err := c.exec(ctx,
+		"UPDATE gallery_images SET status = 'removed', seq = -rowid, r2_key = 'removed/' || id WHERE id = ?",
+		id,
+	)
+	return err
+}
+
+// RestoreGalleryImage undoes MarkGalleryImageRemoved, putting the row back
+// at seq and r2Key.
+func (c *queries) RestoreGalleryImage(ctx context.Context, id string, seq int64, r2Key string) error {
+	id = strings.TrimSpace(id)
+	r2Key = strings.TrimSpace(r2Key)
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if seq < 1 {
+		return fmt.Errorf("seq must be >= 1")
+	}
+	if r2Key == "" {
+		return fmt.Errorf("r2_key is required")
+	}
+	_, err := c.exec(ctx,
+		"UPDATE gallery_images SET status = 'active', seq = ?, r2_key = ? WHERE id = ? AND status = 'removed'",
+		seq, r2Key, id,
+	)
+	return classifyInsertErr(err)
+}
+
+func (c *queries) MoveGalleryImage(ctx context.Context, id string, seq int64, r2Key string) error {
+	id = strings.TrimSpace(id)
+	r2Key = strings.TrimSpace(r2Key)
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if seq < 1 {
+		return fmt.Errorf("seq must be >= 1")
+	}
+	if r2Key == "" {
+		return fmt.Errorf("r2_key is required")
+	}
+	_, err := c.exec(ctx, "UPDATE gallery_images SET seq = ?, r2_key = ? WHERE id = ?", seq, r2Key, id)
+	return classifyInsertErr(err)
+}
+
+func (c *queries) AddBlock(ctx context.Context, key, reason string) error {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("block key is required")
+	}
+	_, err := c.exec(ctx,
+		"INSERT OR REPLACE INTO ingest_blocklist (block_key, reason, created_at) VALUES (?, ?, ?)",
+		key, strings.TrimSpace(reason), time.Now().Unix(),
+	)
+	return err
+}
+
+func rowGalleryImage(row map[string]interface{}) GalleryImage {
+	return GalleryImage{
+		ID:           rowString(row, "id"),
+		Source:       rowString(row, "source"),
+		SourceKey:    rowString(row, "source_key"),
+		SourceURL:    rowString(row, "source_url"),
+		SourcePostID: rowString(row, "source_post_id"),
+		SHA256:       rowString(row, "sha256"),
+		PHash:        rowString(row, "phash"),
+		Orientation:  rowString(row, "orientation"),
+		Seq:          rowInt64(row, "seq"),
+		R2Key:        rowString(row, "r2_key"),
+		Width:        int(rowInt64(row, "width")),
+		Height:       int(rowInt64(row, "height")),
+		Bytes:        rowInt64(row, "bytes"),
+		MimeType:     rowString(row, "mime_type"),
+		PublishedAt:  rowInt64(row, "published_at"),
+		CollectedAt:  rowInt64(row, "collected_at"),
+		Status:       rowString(row, "status"),
+		Title:        rowString(row, "title"),
+		AuthorName:   rowString(row, "author_name"),
+		AuthorID:     rowString(row, "author_id"),
+	}
+}
+
+func classifyInsertErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "UNIQUE constraint failed") {
+		return err
+	}
+	switch {
+	case strings.Contains(msg, "gallery_images.seq"), strings.Contains(msg, "gallery_images.r2_key"):
+		return fmt.Errorf("%w: %v", ErrSeqTaken, err)
+	case strings.Contains(msg, "gallery_images.sha256"):
+		return fmt.Errorf("%w: %v", ErrDuplicateHash, err)
+	case strings.Contains(msg, "gallery_images.source_key"), strings.Contains(msg, "gallery_images.id"):
+		return fmt.Errorf("%w: %v", ErrDuplicateSource, err)
+	}
+	return err
+}
+
+func nullIfEmpty(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+func normalizeOrientation(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "h" || v == "v" {
+		return v
+	}
+	return ""
+}
+
+func gallerySeqBaselineKey(orientation string) string {
+	return "gallery_seq_baseline:" + orientation
+}
+
+func rowString(row map[string]interface{}, key string) string {
+	if row == nil {
+		return ""
+	}
+	v, ok := row[key]
+	if !ok || v == nil {
+		return ""
+	}
+	switch x := v.(type) {
+	case string:
+		return strings.TrimSpace(x)
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%v", x))
+	}
+}
+
+func rowInt64(row map[string]interface{}, key string) int64 {
+	if row == nil {
+		return 0
+	}
+	v, ok := row[key]
+	if !ok || v == nil {
+		return 0
+	}
+	switch x := v.(type) {
+	case float64:
+		return int64(x)
+	case int64:
+		return x
+	case int:
+		return int64(x)
+	case string:
+		n, _ := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+		return n
+	case json.Number:
+		n, _ := x.Int64()
+		return n
+	default:
+		n, _ := strconv.ParseInt(strings.TrimSpace(fmt.Sprintf("%v", x)), 10, 64)
+		return n
+	}
+}
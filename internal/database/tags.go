@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// tagChunkSize keeps each tag statement well under D1's 100 bound
+// parameters per query.
+const tagChunkSize = 50
+
+// NormalizeTags trims, lowercases and collapses inner whitespace of tags,
+// dropping empty ones and duplicates while keeping the first-seen order.
+func NormalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.Join(strings.Fields(t), " "))
+		if t == "" {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+// AddGalleryImageTags links tags to an image, creating missing rows in the
+// tags table. Links that already exist are left alone.
+func (c *queries) AddGalleryImageTags(ctx context.Context, imageID string, tags []string) error {
+	imageID = strings.TrimSpace(imageID)
+	if imageID == "" {
+		return fmt.Errorf("image id is required")
+	}
+	tags = NormalizeTags(tags)
+	for start := 0; start < len(tags); start += tagChunkSize {
+		end := start + tagChunkSize
+		if end > len(tags) {
+			end = len(tags)
+		}
+		chunk := tags[start:end]
+		params := make([]interface{}, 0, len(chunk)+1)
+		for _, t := range chunk {
+			params = append(params, t)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", ")
+		valueRows := strings.TrimSuffix(strings.Repeat("(?), ", len(chunk)), ", ")
+
+		if _, err := c.exec(ctx,
+			"INSERT OR IGNORE INTO tags (name) VALUES "+valueRows,
+			params...,
+		); err != nil {
+			return fmt.Errorf("insert tags: %w", err)
+		}
+		if _, err := c.exec(ctx,
+			"INSERT OR IGNORE INTO gallery_image_tags (image_id, tag_id) SELECT ?, id FROM tags WHERE name IN ("+placeholders+")",
+			append([]interface{}{imageID}, params...)...,
+		); err != nil {
+			return fmt.Errorf("link tags: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListGalleryImageTags returns the tag names of an image, sorted.
+func (c *queries) ListGalleryImageTags(ctx context.Context, imageID string) ([]string, error) {
+	rows, err := c.exec(ctx, `
+		SELECT t.name
+		FROM gallery_image_tags it
+		JOIN tags t ON t.id = it.tag_id
+		WHERE it.image_id = ?
+		ORDER BY t.name
+	`, strings.TrimSpace(imageID))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, rowString(row, "name"))
+	}
+	return out, nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GalleryVariant is a downscaled copy of the image at orientation/seq. Its
+// object key is derived from the slot (see gallery.VariantKey), so variants
+// follow their slot rather than the image row; legacy seqs that predate D1
+// can have variants too.
+type GalleryVariant struct {
+	Orientation string
+	Seq         int64
+	Width       int
+	Height      int
+	Bytes       int64
+	CreatedAt   int64
+}
+
+type GalleryVariantCount struct {
+	Orientation string
+	Width       int
+	Count       int64
+}
+
+func (c *queries) UpsertGalleryVariant(ctx context.Context, v GalleryVariant) error {
+	v.Orientation = normalizeOrientation(v.Orientation)
+	if v.Orientation == "" {
+		return fmt.Errorf("invalid orientation")
+	}
+	if v.Seq < 1 || v.Width < 1 {
+		return fmt.Errorf("seq and width must be >= 1")
+	}
+	if v.CreatedAt <= 0 {
+		v.CreatedAt = time.Now().Unix()
+	}
+	_, err := c.exec(ctx, `
+		INSERT INTO gallery_variants (orientation, seq, width, height, bytes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(orientation, seq, width) DO UPDATE SET
+			height = excluded.height,
+			bytes = excluded.bytes,
+			created_at = excluded.created_at
+	`, v.Orientation, v.Seq, v.Width, v.Height, v.Bytes, v.CreatedAt)
+	return err
+}
+
+func (c *queries) ListGalleryVariants(ctx context.Context, orientation string, seq int64) ([]GalleryVariant, error) {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return nil, fmt.Errorf("invalid orientation")
+	}
+	rows, err := c.exec(ctx, `
+		SELECT orientation, seq, width, height, bytes, created_at
+		FROM gallery_variants
+		WHERE orientation = ? AND seq = ?
+		ORDER BY width
+	`, orientation, seq)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GalleryVariant, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, GalleryVariant{
+			Orientation: rowString(row, "orientation"),
+			Seq:         rowInt64(row, "seq"),
+			Width:       int(rowInt64(row, "width")),
+			Height:      int(rowInt64(row, "height")),
+			Bytes:       rowInt64(row, "bytes"),
+			CreatedAt:   rowInt64(row, "created_at"),
+		})
+	}
+	return out, nil
+}
+
+// ListGalleryVariantSeqs returns every seq of orientation that already has a
+// variant of the given width.
+func (c *queries) ListGalleryVariantSeqs(ctx context.Context, orientation string, width int) ([]int64, error) {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return nil, fmt.Errorf("invalid orientation")
+	}
+	rows, err := c.exec(ctx,
+		"SELECT seq FROM gallery_variants WHERE orientation = ? AND width = ? ORDER BY seq",
+		orientation, width,
+	)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, rowInt64(row, "seq"))
+	}
+	return out, nil
+}
+
+// MoveGalleryVariants hands the variants of fromSeq over to toSeq, dropping
+// whatever toSeq had before. Used when the tail image back-fills a slot.
+func (c *queries) MoveGalleryVariants(ctx context.Context, orientation string, fromSeq, toSeq int64) error {
+	if err := c.DeleteGalleryVariants(ctx, orientation, toSeq); err != nil {
+		return err
+	}
+	_, err := c.exec(ctx,
+		"UPDATE gallery_variants SET seq = ? WHERE orientation = ? AND seq = ?",
+		toSeq, normalizeOrientation(orientation), fromSeq,
+	)
+	return err
+}
+
+func (c *queries) DeleteGalleryVariants(ctx context.Context, orientation string, seq int64) error {
+	orientation = normalizeOrientation(orientation)
+	if orientation == "" {
+		return fmt.Errorf("invalid orientation")
+	}
+	_, err := c.exec(ctx, "DELETE FROM gallery_variants WHERE orientation = ? AND seq = ?", orientation, seq)
+	return err
+}
+
+// CountGalleryVariants reports how many slots of each orientation have a
+// variant of each width.
+func (c *queries) CountGalleryVariants(ctx context.Context) ([]GalleryVariantCount, error) {
+	rows, err := c.exec(ctx, `
+		SELECT orientation, width, COUNT(*) AS c
+		FROM gallery_variants
+		GROUP BY orientation, width
+		ORDER BY orientation, width
+	`)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GalleryVariantCount, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, GalleryVariantCount{
+			Orientation: rowString(row, "orientation"),
+			Width:       int(rowInt64(row, "width")),
+			Count:       rowInt64(row, "c"),
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,191 @@
+package gallery
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// AnimationFrame is one still of an animation with its display time.
+type AnimationFrame struct {
+	Data    []byte // any format img2webp reads (jpg/png/webp)
+	DelayMS int
+}
+
+// AnimationEncoder is implemented by processors that can assemble frames
+// into a single animated WebP.
+type AnimationEncoder interface {
+	EncodeAnimation(ctx context.Context, frames []AnimationFrame) ([]byte, error)
+}
+
+// EncodeAnimation builds a looping animated WebP with img2webp, keeping the
+// per-frame delays.
+func (p *HybridWebPProcessor) EncodeAnimation(ctx context.Context, frames []AnimationFrame) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no animation frames")
+	}
+	bin := "img2webp"
+	quality := 84
+	method := 4
+	if p != nil {
+		if strings.TrimSpace(p.Img2WebPBinary) != "" {
+			bin = strings.TrimSpace(p.Img2WebPBinary)
+		}
+		if p.Quality >= 0 && p.Quality <= 100 {
+			quality = p.Quality
+		}
+		if p.Method >= 0 && p.Method <= 6 {
+			method = p.Method
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "tyr-blog-img-anim-*")
+	if err != nil {
+		return nil, fmt.Errorf("mktemp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	args := []string{"-loop", "0", "-lossy", "-q", strconv.Itoa(quality), "-m", strconv.Itoa(method)}
+	for i, f := range frames {
+		if len(f.Data) == 0 {
+			return nil, fmt.Errorf("frame %d is empty", i)
+		}
+		framePath := filepath.Join(tmpDir, fmt.Sprintf("frame%05d", i))
+		if err := os.WriteFile(framePath, f.Data, 0o600); err != nil {
+			return nil, fmt.Errorf("write frame %d: %w", i, err)
+		}
+		delay := f.DelayMS
+		if delay <= 0 {
+			delay = 100
+		}
+		args = append(args, "-d", strconv.Itoa(delay), framePath)
+	}
+	outPath := filepath.Join(tmpDir, "output.webp")
+	args = append(args, "-o", outPath)
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("img2webp failed: %s", msg)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("read webp output: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("img2webp produced empty output")
+	}
+	return data, nil
+}
+
+// IsAnimatedWebP reports whether data is an extended WebP with the
+// animation flag set.
+func IsAnimatedWebP(data []byte) bool {
+	chunk, ok := findWebPChunk(data, "VP8X")
+	return ok && len(chunk) >= 10 && chunk[0]&0x02 != 0
+}
+
+// animatedWebPSize reads the canvas size from the VP8X chunk.
+func animatedWebPSize(data []byte) (int, int, bool) {
+	chunk, ok := findWebPChunk(data, "VP8X")
+	if !ok || len(chunk) < 10 {
+		return 0, 0, false
+	}
+	w := 1 + int(uint32(chunk[4])|uint32(chunk[5])<<8|uint32(chunk[6])<<16)
+	h := 1 + int(uint32(chunk[7])|uint32(chunk[8])<<8|uint32(chunk[9])<<16)
+	return w, h, true
+}
+
+// decodeStill decodes a still image; for animated WebP, which the webp
+// decoder does not support, it decodes the first frame instead.
+func decodeStill(data []byte) (image.Image, error) {
+	if IsAnimatedWebP(data) {
+		frame, err := firstWebPFrame(data)
+		if err != nil {
+			return nil, err
+		}
+		data = frame
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// firstWebPFrame rewraps the bitstream of the first ANMF chunk as a still
+// WebP file.
+func firstWebPFrame(data []byte) ([]byte, error) {
+	anmf, ok := findWebPChunk(data, "ANMF")
+	if !ok || len(anmf) < 16 {
+		return nil, fmt.Errorf("animated webp has no frames")
+	}
+	w := 1 + int(uint32(anmf[6])|uint32(anmf[7])<<8|uint32(anmf[8])<<16)
+	h := 1 + int(uint32(anmf[9])|uint32(anmf[10])<<8|uint32(anmf[11])<<16)
+	frame := anmf[16:]
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if _, hasAlpha := findChunk(frame, "ALPH"); hasAlpha {
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10 // alpha
+		putUint24(vp8x[4:], w-1)
+		putUint24(vp8x[7:], h-1)
+		writeChunk(&body, "VP8X", vp8x)
+	}
+	body.Write(frame)
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	_ = binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+func findWebPChunk(data []byte, fourCC string) ([]byte, bool) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, false
+	}
+	return findChunk(data[12:], fourCC)
+}
+
+// findChunk returns the payload of the first RIFF chunk named fourCC.
+func findChunk(data []byte, fourCC string) ([]byte, bool) {
+	for len(data) >= 8 {
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if size < 0 || 8+size > len(data) {
+			return nil, false
+		}
+		if string(data[0:4]) == fourCC {
+			return data[8 : 8+size], true
+		}
+		next := 8 + size + size&1
+		if next > len(data) {
+			return nil, false
+		}
+		data = data[next:]
+	}
+	return nil, false
+}
+
+func writeChunk(buf *bytes.Buffer, fourCC string, payload []byte) {
+	buf.WriteString(fourCC)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(payload)))
+	buf.Write(payload)
+	if len(payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func putUint24(b []byte, v int) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
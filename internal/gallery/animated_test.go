@@ -0,0 +1,62 @@
+package gallery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func buildWebP(chunks ...[]byte) []byte {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	for _, c := range chunks {
+		body.Write(c)
+	}
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	_ = binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func chunk(fourCC string, payload []byte) []byte {
+	var buf bytes.Buffer
+	writeChunk(&buf, fourCC, payload)
+	return buf.Bytes()
+}
+
+func TestAnimatedWebPHelpers(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 // animation
+	putUint24(vp8x[4:], 640-1)
+	putUint24(vp8x[7:], 960-1)
+
+	frameBits := chunk("VP8L", []byte{0x2f, 1, 2}) // odd length exercises padding
+	anmf := make([]byte, 16)
+	putUint24(anmf[6:], 640-1)
+	putUint24(anmf[9:], 960-1)
+	anmf = append(anmf, frameBits...)
+
+	data := buildWebP(chunk("VP8X", vp8x), chunk("ANIM", make([]byte, 6)), chunk("ANMF", anmf))
+	if !IsAnimatedWebP(data) {
+		t.Fatal("IsAnimatedWebP = false, want true")
+	}
+	if w, h, ok := animatedWebPSize(data); !ok || w != 640 || h != 960 {
+		t.Fatalf("animatedWebPSize = %d,%d,%v, want 640,960,true", w, h, ok)
+	}
+
+	still, err := firstWebPFrame(data)
+	if err != nil {
+		t.Fatalf("firstWebPFrame: %v", err)
+	}
+	if IsAnimatedWebP(still) {
+		t.Fatal("first frame should be a still webp")
+	}
+	if got, ok := findWebPChunk(still, "VP8L"); !ok || !bytes.Equal(got, []byte{0x2f, 1, 2}) {
+		t.Fatalf("first frame VP8L chunk = %v,%v", got, ok)
+	}
+
+	if IsAnimatedWebP(buildWebP(chunk("VP8L", []byte{0x2f}))) {
+		t.Fatal("simple webp reported as animated")
+	}
+}
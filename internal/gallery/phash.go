@@ -0,0 +1,96 @@
+package gallery
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// DefaultPHashMaxDistance is the Hamming distance (out of 64 bits) under which
+// two dHashes are treated as the same artwork. It tolerates rescaling and
+// JPEG recompression but not crops or edits.
+const DefaultPHashMaxDistance = 5
+
+const (
+	dhashCols = 9
+	dhashRows = 8
+	// dhashSamples is the per-axis sample count inside each grid cell; sampling
+	// keeps hashing cheap on very large originals.
+	dhashSamples = 8
+)
+
+// DHash computes a 64-bit difference hash: the image is reduced to a 9x8
+// grayscale grid and each bit records whether a cell is darker than its
+// right neighbour.
+func DHash(img image.Image) uint64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+
+	var grid [dhashRows][dhashCols]float64
+	for gy := 0; gy < dhashRows; gy++ {
+		for gx := 0; gx < dhashCols; gx++ {
+			x0 := b.Min.X + gx*w/dhashCols
+			x1 := b.Min.X + (gx+1)*w/dhashCols
+			y0 := b.Min.Y + gy*h/dhashRows
+			y1 := b.Min.Y + (gy+1)*h/dhashRows
+			grid[gy][gx] = sampleLuma(img, x0, x1, y0, y1)
+		}
+	}
+
+	var hash uint64
+	for gy := 0; gy < dhashRows; gy++ {
+		for gx := 0; gx < dhashCols-1; gx++ {
+			hash <<= 1
+			if grid[gy][gx] < grid[gy][gx+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func sampleLuma(img image.Image, x0, x1, y0, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	var sum float64
+	n := 0
+	for sy := 0; sy < dhashSamples; sy++ {
+		y := y0 + (2*sy+1)*(y1-y0)/(2*dhashSamples)
+		for sx := 0; sx < dhashSamples; sx++ {
+			x := x0 + (2*sx+1)*(x1-x0)/(2*dhashSamples)
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+func FormatPHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func ParsePHash(v string) (uint64, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(v, 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,59 @@
+package gallery
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"tyr-blog-img/internal/database"
+)
+
+// phashIndex keeps the dHash of every stored row in memory so the
+// near-duplicate check does not read the whole table per ingest. The first
+// refresh loads everything; later ones only fetch rows added since, which
+// also picks up images stored by other instances.
+type phashIndex struct {
+	mu      sync.Mutex
+	lastRow int64
+	entries []phashEntry
+}
+
+type phashEntry struct {
+	hash uint64
+	id   string
+}
+
+type phashMatch struct {
+	id   string
+	dist int
+}
+
+func (x *phashIndex) refresh(ctx context.Context, db database.Store) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	rows, err := db.ListGalleryPHashes(ctx, x.lastRow)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if hash, ok := ParsePHash(r.PHash); ok {
+			x.entries = append(x.entries, phashEntry{hash: hash, id: r.ID})
+		}
+		x.lastRow = max(x.lastRow, r.RowID)
+	}
+	return nil
+}
+
+// within returns the rows at most maxDist away from hash, closest first.
+func (x *phashIndex) within(hash uint64, maxDist int) []phashMatch {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var out []phashMatch
+	for _, e := range x.entries {
+		if dist := HammingDistance(hash, e.hash); dist <= maxDist {
+			out = append(out, phashMatch{id: e.id, dist: dist})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].dist < out[j].dist })
+	return out
+}
@@ -0,0 +1,50 @@
+package gallery
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func testPattern(w, h int, invert bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Diagonal bands with a bright blob so the hash has structure on both axes.
+			v := uint8((x*255/w + y*128/h) % 256)
+			dx, dy := x-w/3, y-h/2
+			if dx*dx+dy*dy < (w/6)*(w/6) {
+				v = 250
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestDHashToleratesRescale(t *testing.T) {
+	big := DHash(testPattern(1800, 1200, false))
+	small := DHash(testPattern(450, 300, false))
+	if d := HammingDistance(big, small); d > DefaultPHashMaxDistance {
+		t.Fatalf("rescaled distance = %d, want <= %d", d, DefaultPHashMaxDistance)
+	}
+
+	other := DHash(testPattern(1800, 1200, true))
+	if d := HammingDistance(big, other); d <= DefaultPHashMaxDistance {
+		t.Fatalf("different image distance = %d, want > %d", d, DefaultPHashMaxDistance)
+	}
+}
+
+func TestParsePHashRoundTrip(t *testing.T) {
+	const hash = uint64(0x0123456789abcdef)
+	got, ok := ParsePHash(FormatPHash(hash))
+	if !ok || got != hash {
+		t.Fatalf("ParsePHash(FormatPHash(%x)) = %x, %v", hash, got, ok)
+	}
+	if _, ok := ParsePHash(""); ok {
+		t.Fatalf("ParsePHash(\"\") ok, want false")
+	}
+}
@@ -22,8 +22,13 @@ import (
 )
 
 type PreparedImage struct {
-	WebPBytes    []byte
-	SHA256       string
+	WebPBytes []byte
+	SHA256    string
+	PHash     string // dHash of the decoded image, see DHash
+	// Variants are the downscaled copies stored at VariantKey; filled by
+	// StoreToGallery when the processor does not provide them.
+	Variants []PreparedVariant
+
 	Width        int
 	Height       int
 	Orientation  string
@@ -38,6 +43,7 @@ type ImageProcessor interface {
 
 type HybridWebPProcessor struct {
 	CWebPBinary     string
+	Img2WebPBinary  string
 	Quality         int
 	Method          int
 	PassThroughWebP bool
@@ -46,6 +52,7 @@ type HybridWebPProcessor struct {
 func NewHybridWebPProcessor() *HybridWebPProcessor {
 	return &HybridWebPProcessor{
 		CWebPBinary:     "cwebp",
+		Img2WebPBinary:  "img2webp",
 		Quality:         84,
 		Method:          4,
 		PassThroughWebP: true,
@@ -103,6 +110,9 @@ func (p *HybridWebPProcessor) Prepare(ctx context.Context, data []byte) (Prepare
 	}
 
 	mime := strings.ToLower(strings.TrimSpace(http.DetectContentType(data)))
+	if IsAnimatedWebP(data) {
+		return prepareAnimatedWebP(data, mime)
+	}
 	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
 	if err != nil {
 		return PreparedImage{}, fmt.Errorf("decode image config: %w", err)
@@ -116,12 +126,14 @@ func (p *HybridWebPProcessor) Prepare(ctx context.Context, data []byte) (Prepare
 		orientation = "v"
 	}
 
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return PreparedImage{}, fmt.Errorf("decode image: %w", err)
+	}
+	phash := FormatPHash(DHash(decoded))
+
 	webpBytes := data
 	if !(p.PassThroughWebP && (format == "webp" || strings.Contains(mime, "webp"))) {
-		decoded, _, err := image.Decode(bytes.NewReader(data))
-		if err != nil {
-			return PreparedImage{}, fmt.Errorf("decode image: %w", err)
-		}
 		webpBytes, err = p.encodeWithCWebP(ctx, decoded)
 		if err != nil {
 			return PreparedImage{}, err
@@ -146,6 +158,7 @@ func (p *HybridWebPProcessor) Prepare(ctx context.Context, data []byte) (Prepare
 	return PreparedImage{
 		WebPBytes:    webpBytes,
 		SHA256:       sha,
+		PHash:        phash,
 		Width:        cfg.Width,
 		Height:       cfg.Height,
 		Orientation:  orientation,
@@ -155,6 +168,35 @@ func (p *HybridWebPProcessor) Prepare(ctx context.Context, data []byte) (Prepare
 	}, nil
 }
 
+// prepareAnimatedWebP stores animated WebP as is, since cwebp would flatten
+// it; the perceptual hash comes from the first frame.
+func prepareAnimatedWebP(data []byte, mime string) (PreparedImage, error) {
+	width, height, ok := animatedWebPSize(data)
+	if !ok || width <= 0 || height <= 0 {
+		return PreparedImage{}, fmt.Errorf("invalid animated webp header")
+	}
+	first, err := decodeStill(data)
+	if err != nil {
+		return PreparedImage{}, fmt.Errorf("decode first frame: %w", err)
+	}
+	orientation := "h"
+	if height > width {
+		orientation = "v"
+	}
+	hash := sha256.Sum256(data)
+	return PreparedImage{
+		WebPBytes:    data,
+		SHA256:       hex.EncodeToString(hash[:]),
+		PHash:        FormatPHash(DHash(first)),
+		Width:        width,
+		Height:       height,
+		Orientation:  orientation,
+		Bytes:        int64(len(data)),
+		ContentType:  "image/webp",
+		OriginalMIME: mime,
+	}, nil
+}
+
 func (p *HybridWebPProcessor) encodeWithCWebP(ctx context.Context, img image.Image) ([]byte, error) {
 	bin := "cwebp"
 	quality := 84
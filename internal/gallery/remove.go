@@ -0,0 +1,137 @@
+package gallery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"tyr-blog-img/internal/database"
+)
+
+type RemoveResult struct {
+	Removed database.GalleryImage
+	// Moved is the image that was moved from the tail into the freed slot;
+	// nil when the removed image already held the highest seq.
+	Moved    *database.GalleryImage
+	MovedSeq int64
+	Counts   database.GalleryCounts
+}
+
+// RemoveImage takes down the active image at orientation/seq. Its source key
+// is blocklisted and the highest-seq image of the same orientation is moved
+// into the freed slot, so seqs stay contiguous and random.js never picks a
+// missing number. Takedowns are serialized with each other and with seq
+// allocation, so the tail cannot change while it is being moved.
+func (s *Service) RemoveImage(ctx context.Context, orientation string, seq int64, reason string) (RemoveResult, error) {
+	return s.TakeDownImage(ctx, orientation, seq, reason, true)
+}
+
+// TakeDownImage is RemoveImage with the blocklist entry optional. It does
+// not make the source ingestible again: the removed row keeps its source
+// key, sha256 and phash, which dedupe on their own.
+func (s *Service) TakeDownImage(ctx context.Context, orientation string, seq int64, reason string, block bool) (RemoveResult, error) {
+	if s == nil || s.DB == nil || s.Store == nil {
+		return RemoveResult{}, fmt.Errorf("gallery service not fully configured")
+	}
+	orientation = strings.ToLower(strings.TrimSpace(orientation))
+	if orientation != "h" && orientation != "v" {
+		return RemoveResult{}, fmt.Errorf("invalid orientation %q", orientation)
+	}
+	if seq < 1 {
+		return RemoveResult{}, fmt.Errorf("seq must be >= 1")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "removed"
+	}
+
+	s.slots.Lock()
+	defer s.slots.Unlock()
+
+	target, ok, err := s.DB.GetGalleryImage(ctx, orientation, seq)
+	if err != nil {
+		return RemoveResult{}, err
+	}
+	if !ok || target.Status != "active" {
+		return RemoveResult{}, fmt.Errorf("no active image at %s/%d", orientation, seq)
+	}
+	last, ok, err := s.DB.LastGalleryImage(ctx, orientation)
+	if err != nil {
+		return RemoveResult{}, err
+	}
+	if !ok {
+		return RemoveResult{}, fmt.Errorf("no active %s images", orientation)
+	}
+
+	// 1) Blocklist first so the image cannot be re-ingested while we work.
+	if block {
+		if err := s.DB.AddBlock(ctx, target.SourceKey, reason); err != nil {
+			return RemoveResult{}, fmt.Errorf("blocklist %s: %w", target.SourceKey, err)
+		}
+	}
+
+	res := RemoveResult{Removed: target}
+	if last.Seq == target.Seq {
+		// 2a) Tail image: drop it and give the seq back.
+		if err := s.DB.MarkGalleryImageRemoved(ctx, target.ID); err != nil {
+			return RemoveResult{}, fmt.Errorf("mark removed: %w", err)
+		}
+		if err := s.Store.DeleteObject(ctx, target.R2Key); err != nil {
+			return RemoveResult{}, fmt.Errorf("delete r2 %s: %w", target.R2Key, err)
+		}
+		if err := s.deleteVariants(ctx, orientation, target.Seq); err != nil {
+			return RemoveResult{}, fmt.Errorf("delete variants %s/%d: %w", orientation, target.Seq, err)
+		}
+	} else {
+		// 2b) Copy the tail image over the freed slot before touching D1,
+		// so the slot always serves some image.
+		if err := s.copySlotObject(ctx, last.R2Key, target.R2Key); err != nil {
+			return RemoveResult{}, fmt.Errorf("copy r2 %s -> %s: %w", last.R2Key, target.R2Key, err)
+		}
+		if err := s.DB.MarkGalleryImageRemoved(ctx, target.ID); err != nil {
+			return RemoveResult{}, fmt.Errorf("mark removed: %w", err)
+		}
+		if err := s.DB.MoveGalleryImage(ctx, last.ID, target.Seq, target.R2Key); err != nil {
+			// Put the row back so the takedown can simply be retried; until
+			// then the slot serves a copy of the tail image.
+			if rerr := s.DB.RestoreGalleryImage(context.WithoutCancel(ctx), target.ID, target.Seq, target.R2Key); rerr != nil {
+				slog.ErrorContext(ctx, "gallery takedown rollback failed", "id", target.ID, "seq", target.Seq, "err", rerr)
+			}
+			return RemoveResult{}, fmt.Errorf("move %s/%d -> %d: %w", orientation, last.Seq, target.Seq, err)
+		}
+		if err := s.Store.DeleteObject(ctx, last.R2Key); err != nil {
+			return RemoveResult{}, fmt.Errorf("delete r2 %s: %w", last.R2Key, err)
+		}
+		if err := s.moveVariants(ctx, orientation, last.Seq, target.Seq); err != nil {
+			return RemoveResult{}, fmt.Errorf("move variants %s/%d -> %d: %w", orientation, last.Seq, target.Seq, err)
+		}
+		moved := last
+		moved.Seq = target.Seq
+		moved.R2Key = target.R2Key
+		res.Moved = &moved
+		res.MovedSeq = last.Seq
+	}
+
+	// 3) The tail seq is free now; rewind the allocator unless someone
+	// allocated past it meanwhile.
+	if err := s.DB.ReleaseGallerySeq(ctx, orientation, last.Seq); err != nil {
+		return RemoveResult{}, fmt.Errorf("release seq: %w", err)
+	}
+
+	if counts, err := s.DB.CountGalleryActive(ctx); err == nil {
+		res.Counts = counts
+	}
+	return res, nil
+}
+
+// copySlotObject rewrites dst with the content of src. It re-uploads rather
+// than copying server side so dst gets SlotCacheControl even when src was
+// uploaded as immutable by an older version.
+func (s *Service) copySlotObject(ctx context.Context, src, dst string) error {
+	data, contentType, err := s.Store.GetObject(ctx, src)
+	if err != nil {
+		return err
+	}
+	return s.Store.PutObjectWithCacheControl(ctx, dst, data, contentType, SlotCacheControl)
+}
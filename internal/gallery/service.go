@@ -2,26 +2,54 @@ package gallery
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
 	"time"
 
 	"tyr-blog-img/internal/database"
+	"tyr-blog-img/internal/logging"
+	"tyr-blog-img/internal/metrics"
 )
 
+// maxSeqAttempts bounds how many seqs one StoreToGallery call may burn when
+// it keeps losing races for a key.
+const maxSeqAttempts = 5
+
+// SlotCacheControl is sent with seq images and their variants. A takedown
+// rewrites the slot with the tail image, so the objects must not be cached
+// as immutable; max-age bounds how long a CDN keeps serving a removed image.
+const SlotCacheControl = "public, max-age=3600"
+
 type ObjectStore interface {
-	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+	PutObjectWithCacheControl(ctx context.Context, key string, data []byte, contentType, cacheControl string) error
+	// PutObjectIfAbsent uploads only when key does not exist yet and
+	// reports whether the object was created.
+	PutObjectIfAbsent(ctx context.Context, key string, data []byte, contentType, cacheControl string) (bool, error)
+	GetObject(ctx context.Context, key string) ([]byte, string, error)
 	DeleteObject(ctx context.Context, key string) error
 }
 
 type Service struct {
-	DB        *database.Client
+	DB        database.Store
 	Store     ObjectStore
 	Processor ImageProcessor
 
-	muH sync.Mutex
-	muV sync.Mutex
+	// PHashMaxDistance is the largest dHash Hamming distance still treated
+	// as a near duplicate. Negative disables the check.
+	PHashMaxDistance int
+	phashes          phashIndex
+
+	// VariantWidths are the downscaled copies stored next to each image at
+	// VariantKey. Empty disables variants.
+	VariantWidths []int
+
+	// slots is held shared while a seq slot is written and exclusively by
+	// takedowns, which move the tail image and must not see a new tail
+	// appear halfway through.
+	slots sync.RWMutex
 }
 
 type StoreInput struct {
@@ -33,6 +61,12 @@ type StoreInput struct {
 	RawData      []byte
 	PublishedAt  int64
 	CollectedAt  int64
+
+	// Optional credits recorded with the image.
+	Title      string
+	AuthorName string
+	AuthorID   string
+	Tags       []string
 }
 
 type StoreResult struct {
@@ -43,18 +77,40 @@ type StoreResult struct {
 	ContentHash string
 }
 
-func NewService(db *database.Client, store ObjectStore, processor ImageProcessor) *Service {
+func NewService(db database.Store, store ObjectStore, processor ImageProcessor) *Service {
 	if processor == nil {
 		processor = NewHybridWebPProcessor()
 	}
 	return &Service{
-		DB:        db,
-		Store:     store,
-		Processor: processor,
+		DB:               db,
+		Store:            store,
+		Processor:        processor,
+		PHashMaxDistance: DefaultPHashMaxDistance,
+		VariantWidths:    append([]int(nil), DefaultVariantWidths...),
 	}
 }
 
 func (s *Service) StoreToGallery(ctx context.Context, in StoreInput) (StoreResult, error) {
+	if in.Source = strings.TrimSpace(in.Source); in.Source == "" {
+		in.Source = "unknown"
+	}
+	ctx = logging.WithIngest(ctx, in.Source, strings.TrimSpace(in.SourceKey))
+	ctx = logging.With(ctx, slog.String("source_key", strings.TrimSpace(in.SourceKey)))
+
+	res, err := s.storeToGallery(ctx, in)
+	metrics.ObserveStore(in.Source, res.Added, res.SkipReason, err)
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "gallery store failed", "err", err)
+	case res.Added:
+		slog.InfoContext(ctx, "gallery image stored", "orientation", res.Image.Orientation, "seq", res.Image.Seq, "bytes", res.Image.Bytes)
+	default:
+		slog.InfoContext(ctx, "gallery image skipped", "reason", res.SkipReason)
+	}
+	return res, err
+}
+
+func (s *Service) storeToGallery(ctx context.Context, in StoreInput) (StoreResult, error) {
 	if s == nil || s.DB == nil || s.Store == nil || s.Processor == nil {
 		return StoreResult{}, fmt.Errorf("gallery service not fully configured")
 	}
@@ -94,10 +150,14 @@ func (s *Service) StoreToGallery(ctx context.Context, in StoreInput) (StoreResul
 	}
 
 	// 3) Prepare image (hash + dimensions + orientation + webp bytes)
+	prepareStart := time.Now()
 	prepared, err := s.Processor.Prepare(ctx, in.RawData)
+	metrics.ObservePrepare(time.Since(prepareStart))
 	if err != nil {
 		return StoreResult{}, err
 	}
+	slog.DebugContext(ctx, "gallery image prepared", "sha256", prepared.SHA256, "orientation", prepared.Orientation,
+		"width", prepared.Width, "height", prepared.Height, "bytes", prepared.Bytes, "took", time.Since(prepareStart))
 
 	// 4) Content-level dedupe (after bytes/hash available)
 	existsHash, err := s.DB.ExistsGallerySHA256(ctx, prepared.SHA256)
@@ -108,66 +168,110 @@ func (s *Service) StoreToGallery(ctx context.Context, in StoreInput) (StoreResul
 		return StoreResult{SkipReason: "duplicate_hash", ContentHash: prepared.SHA256}, nil
 	}
 
-	// 5) Per-orientation critical section (single instance MVP)
-	lock := s.orientationLock(prepared.Orientation)
-	lock.Lock()
-	defer lock.Unlock()
-
-	// Recheck in critical section to reduce race window.
-	existsSource, err = s.DB.ExistsGallerySourceKey(ctx, in.SourceKey)
-	if err != nil {
-		return StoreResult{}, err
-	}
-	if existsSource {
-		return StoreResult{SkipReason: "duplicate_source_race", ContentHash: prepared.SHA256}, nil
-	}
-	existsHash, err = s.DB.ExistsGallerySHA256(ctx, prepared.SHA256)
-	if err != nil {
-		return StoreResult{}, err
-	}
-	if existsHash {
-		return StoreResult{SkipReason: "duplicate_hash_race", ContentHash: prepared.SHA256}, nil
-	}
-
-	// 6) Allocate seq as late as possible (after dedupe + prepare succeeds)
-	seq, err := s.DB.NextGallerySeq(ctx, prepared.Orientation)
-	if err != nil {
+	// 4b) Perceptual dedupe: the same artwork re-posted at another size or
+	// quality has a different sha256 but a nearby dHash.
+	if match, dist, ok, err := s.findNearDuplicate(ctx, prepared.PHash); err != nil {
 		return StoreResult{}, err
+	} else if ok {
+		return StoreResult{
+			SkipReason:  nearDuplicateReason(match, dist),
+			ContentHash: prepared.SHA256,
+		}, nil
 	}
-	r2Key := fmt.Sprintf("ri/%s/%d.webp", prepared.Orientation, seq)
 
-	// 7) Upload to R2 first; if this fails, no seq is persisted in D1.
-	if err := s.Store.PutObject(ctx, r2Key, prepared.WebPBytes, prepared.ContentType); err != nil {
-		return StoreResult{}, fmt.Errorf("upload r2 %s: %w", r2Key, err)
-	}
+	// 4c) Render width variants now that the image is known to be new.
+	prepared.Variants = s.prepareVariants(ctx, prepared)
 
 	collectedAt := in.CollectedAt
 	if collectedAt <= 0 {
 		collectedAt = time.Now().Unix()
 	}
-	img := database.GalleryImage{
-		ID:           pickID(in.ID, in.SourceKey, prepared.SHA256),
-		Source:       in.Source,
-		SourceKey:    in.SourceKey,
-		SourceURL:    in.SourceURL,
-		SourcePostID: in.SourcePostID,
-		SHA256:       prepared.SHA256,
-		Orientation:  prepared.Orientation,
-		Seq:          seq,
-		R2Key:        r2Key,
-		Width:        prepared.Width,
-		Height:       prepared.Height,
-		Bytes:        prepared.Bytes,
-		MimeType:     prepared.ContentType,
-		PublishedAt:  in.PublishedAt,
-		CollectedAt:  collectedAt,
-		Status:       "active",
-	}
-
-	// 8) Persist D1 record. If this fails, try cleanup R2 object to avoid orphans.
-	if err := s.DB.InsertGalleryImage(ctx, img); err != nil {
+
+	// 5) Allocate seq as late as possible (after dedupe + prepare succeeds).
+	// Allocation is atomic in the DB and the object upload never overwrites an
+	// existing key, so several instances can ingest concurrently.
+	s.slots.RLock()
+	defer s.slots.RUnlock()
+	var img database.GalleryImage
+	for attempt := 1; ; attempt++ {
+		if attempt > maxSeqAttempts {
+			return StoreResult{}, fmt.Errorf("allocate %s seq: gave up after %d attempts", prepared.Orientation, maxSeqAttempts)
+		}
+		seq, err := s.DB.AllocateGallerySeq(ctx, prepared.Orientation)
+		if err != nil {
+			return StoreResult{}, err
+		}
+		r2Key := fmt.Sprintf("ri/%s/%d.webp", prepared.Orientation, seq)
+
+		// 6) Upload to R2 first; if this fails, no seq is persisted in D1.
+		created, err := s.Store.PutObjectIfAbsent(ctx, r2Key, prepared.WebPBytes, prepared.ContentType, SlotCacheControl)
+		if err != nil {
+			s.releaseSeq(prepared.Orientation, seq)
+			return StoreResult{}, fmt.Errorf("upload r2 %s: %w", r2Key, err)
+		}
+		if !created {
+			// The key already holds an image (another instance or a legacy
+			// upload); leave it alone and take the next seq.
+			slog.DebugContext(ctx, "gallery seq taken in object store", "key", r2Key, "attempt", attempt)
+			continue
+		}
+		slog.DebugContext(ctx, "gallery object uploaded", "key", r2Key)
+
+		img = database.GalleryImage{
+			ID:           pickID(in.ID, in.SourceKey, prepared.SHA256),
+			Source:       in.Source,
+			SourceKey:    in.SourceKey,
+			SourceURL:    in.SourceURL,
+			SourcePostID: in.SourcePostID,
+			SHA256:       prepared.SHA256,
+			PHash:        prepared.PHash,
+			Orientation:  prepared.Orientation,
+			Seq:          seq,
+			R2Key:        r2Key,
+			Width:        prepared.Width,
+			Height:       prepared.Height,
+			Bytes:        prepared.Bytes,
+			MimeType:     prepared.ContentType,
+			PublishedAt:  in.PublishedAt,
+			CollectedAt:  collectedAt,
+			Status:       "active",
+			Title:        in.Title,
+			AuthorName:   in.AuthorName,
+			AuthorID:     in.AuthorID,
+		}
+
+		// 7) Persist D1 record. If this fails, clean up our R2 object to avoid orphans.
+		err = s.DB.InsertGalleryImage(ctx, img)
+		if err == nil {
+			break
+		}
 		_ = s.Store.DeleteObject(context.Background(), r2Key)
-		return StoreResult{}, fmt.Errorf("insert gallery image: %w", err)
+		switch {
+		case errors.Is(err, database.ErrSeqTaken):
+			continue
+		case errors.Is(err, database.ErrDuplicateSource):
+			s.releaseSeq(prepared.Orientation, seq)
+			return StoreResult{SkipReason: "duplicate_source_race", ContentHash: prepared.SHA256}, nil
+		case errors.Is(err, database.ErrDuplicateHash):
+			s.releaseSeq(prepared.Orientation, seq)
+			return StoreResult{SkipReason: "duplicate_hash_race", ContentHash: prepared.SHA256}, nil
+		default:
+			s.releaseSeq(prepared.Orientation, seq)
+			return StoreResult{}, fmt.Errorf("insert gallery image: %w", err)
+		}
+	}
+
+	// Tags are credits only; the image is stored either way.
+	if len(in.Tags) > 0 {
+		if err := s.DB.AddGalleryImageTags(ctx, img.ID, in.Tags); err != nil {
+			slog.WarnContext(ctx, "gallery tags failed", "id", img.ID, "err", err)
+		}
+	}
+
+	// 8) Variants only once the slot is ours; a failure leaves the image
+	// servable and BackfillVariants can retry later.
+	if err := s.storeVariants(ctx, img.Orientation, img.Seq, prepared.Variants); err != nil {
+		slog.WarnContext(ctx, "gallery variants upload failed", "orientation", img.Orientation, "seq", img.Seq, "err", err)
 	}
 
 	counts, err := s.DB.CountGalleryActive(ctx)
@@ -187,11 +291,56 @@ func (s *Service) StoreToGallery(ctx context.Context, in StoreInput) (StoreResul
 	}, nil
 }
 
-func (s *Service) orientationLock(orientation string) *sync.Mutex {
-	if strings.EqualFold(strings.TrimSpace(orientation), "v") {
-		return &s.muV
+// findNearDuplicate returns the stored image whose phash is closest to phash,
+// if it is within PHashMaxDistance.
+func (s *Service) findNearDuplicate(ctx context.Context, phash string) (database.GalleryImage, int, bool, error) {
+	if s.PHashMaxDistance < 0 {
+		return database.GalleryImage{}, 0, false, nil
+	}
+	hash, ok := ParsePHash(phash)
+	if !ok {
+		return database.GalleryImage{}, 0, false, nil
+	}
+	if err := s.phashes.refresh(ctx, s.DB); err != nil {
+		return database.GalleryImage{}, 0, false, fmt.Errorf("list phashes: %w", err)
+	}
+	var (
+		best     database.GalleryImage
+		bestDist = -1
+	)
+	// Matches come closest first; among equally close ones an active image
+	// is preferred so the reason points at a seq.
+	for _, m := range s.phashes.within(hash, s.PHashMaxDistance) {
+		if bestDist >= 0 && (m.dist > bestDist || best.Status == "active") {
+			break
+		}
+		img, ok, err := s.DB.GetGalleryImageByID(ctx, m.id)
+		if err != nil {
+			return database.GalleryImage{}, 0, false, fmt.Errorf("load phash match %s: %w", m.id, err)
+		}
+		if ok && (bestDist < 0 || img.Status == "active") {
+			best, bestDist = img, m.dist
+		}
+	}
+	if bestDist < 0 {
+		return database.GalleryImage{}, 0, false, nil
 	}
-	return &s.muH
+	return best, bestDist, true, nil
+}
+
+func nearDuplicateReason(match database.GalleryImage, dist int) string {
+	if match.Status == "active" && match.Seq > 0 {
+		return fmt.Sprintf("near_duplicate of %s/%d, distance %d", match.Orientation, match.Seq, dist)
+	}
+	return fmt.Sprintf("near_duplicate of removed %s, distance %d", match.SourceKey, dist)
+}
+
+// releaseSeq rewinds the counter for a seq that ended up unused. It runs on
+// a fresh context so a cancelled ingest still gives its seq back.
+func (s *Service) releaseSeq(orientation string, seq int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = s.DB.ReleaseGallerySeq(ctx, orientation, seq)
 }
 
 func pickID(preferred, sourceKey, sha string) string {
@@ -0,0 +1,261 @@
+package gallery
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"tyr-blog-img/internal/database"
+	"tyr-blog-img/internal/storage"
+)
+
+// fakeProcessor skips decoding and cwebp so tests can feed arbitrary bytes.
+type fakeProcessor struct{}
+
+func (fakeProcessor) Prepare(_ context.Context, data []byte) (PreparedImage, error) {
+	sum := sha256.Sum256(data)
+	return PreparedImage{
+		WebPBytes:   data,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Width:       1600,
+		Height:      900,
+		Orientation: "h",
+		Bytes:       int64(len(data)),
+		ContentType: "image/webp",
+	}, nil
+}
+
+func newTestService(t *testing.T) (*Service, *storage.FSClient) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := database.NewSQLite(filepath.Join(dir, "gallery.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.EnsureSchema(context.Background()); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+	fs, err := storage.NewFSClient(storage.FSConfig{Root: filepath.Join(dir, "objects")})
+	if err != nil {
+		t.Fatalf("NewFSClient: %v", err)
+	}
+	return NewService(db, fs, fakeProcessor{}), fs
+}
+
+func TestStoreToGalleryNeverOverwritesExistingObject(t *testing.T) {
+	ctx := context.Background()
+	svc, fs := newTestService(t)
+
+	legacy := []byte("legacy-h-1")
+	if err := fs.PutObject(ctx, "ri/h/1.webp", legacy, "image/webp"); err != nil {
+		t.Fatalf("seed legacy object: %v", err)
+	}
+
+	res, err := svc.StoreToGallery(ctx, StoreInput{Source: "tg", SourceKey: "tgfile_1", RawData: []byte("new image")})
+	if err != nil {
+		t.Fatalf("StoreToGallery: %v", err)
+	}
+	if !res.Added || res.Image.Seq != 2 {
+		t.Fatalf("result = %+v, want added at seq 2", res)
+	}
+	got, _, err := fs.GetObject(ctx, "ri/h/1.webp")
+	if err != nil || !bytes.Equal(got, legacy) {
+		t.Fatalf("legacy object = %q, %v; want untouched", got, err)
+	}
+
+	res, err = svc.StoreToGallery(ctx, StoreInput{Source: "tg", SourceKey: "tgfile_2", RawData: []byte("new image")})
+	if err != nil {
+		t.Fatalf("StoreToGallery duplicate: %v", err)
+	}
+	if res.Added || res.SkipReason != "duplicate_hash" {
+		t.Fatalf("duplicate result = %+v, want duplicate_hash skip", res)
+	}
+}
+
+func TestRemoveImageBackfillsFromTail(t *testing.T) {
+	ctx := context.Background()
+	svc, fs := newTestService(t)
+
+	for i, key := range []string{"a", "b", "c"} {
+		res, err := svc.StoreToGallery(ctx, StoreInput{Source: "tg", SourceKey: key, RawData: []byte("img-" + key)})
+		if err != nil || !res.Added || res.Image.Seq != int64(i+1) {
+			t.Fatalf("seed %s = %+v, %v", key, res, err)
+		}
+	}
+
+	res, err := svc.RemoveImage(ctx, "h", 1, "test")
+	if err != nil {
+		t.Fatalf("RemoveImage: %v", err)
+	}
+	if res.Moved == nil || res.MovedSeq != 3 || res.Moved.SourceKey != "c" {
+		t.Fatalf("moved = %+v from %d, want c from 3", res.Moved, res.MovedSeq)
+	}
+	if res.Counts.H != 2 {
+		t.Fatalf("counts.h = %d, want 2", res.Counts.H)
+	}
+	if got, _, err := fs.GetObject(ctx, "ri/h/1.webp"); err != nil || string(got) != "img-c" {
+		t.Fatalf("slot 1 = %q, %v; want img-c", got, err)
+	}
+	if _, _, err := fs.GetObject(ctx, "ri/h/3.webp"); err == nil {
+		t.Fatalf("tail object ri/h/3.webp still exists")
+	}
+	if blocked, _ := svc.DB.IsBlocked(ctx, "a"); !blocked {
+		t.Fatalf("source key a not blocklisted")
+	}
+
+	// The freed tail seq is handed out again.
+	next, err := svc.StoreToGallery(ctx, StoreInput{Source: "tg", SourceKey: "d", RawData: []byte("img-d")})
+	if err != nil || next.Image.Seq != 3 {
+		t.Fatalf("next store = %+v, %v; want seq 3", next, err)
+	}
+
+	if _, err := svc.RemoveImage(ctx, "h", 3, "test"); err != nil {
+		t.Fatalf("RemoveImage tail: %v", err)
+	}
+	if _, ok, _ := svc.DB.GetGalleryImage(ctx, "h", 3); ok {
+		t.Fatalf("tail row still holds seq 3")
+	}
+}
+
+// failMoveStore fails every MoveGalleryImage, to exercise the takedown
+// rollback.
+type failMoveStore struct {
+	database.Store
+}
+
+func (failMoveStore) MoveGalleryImage(context.Context, string, int64, string) error {
+	return fmt.Errorf("d1 unavailable")
+}
+
+func TestRemoveImageRestoresRowWhenMoveFails(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newTestService(t)
+	for _, key := range []string{"a", "b"} {
+		if res, err := svc.StoreToGallery(ctx, StoreInput{Source: "tg", SourceKey: key, RawData: []byte("img-" + key)}); err != nil || !res.Added {
+			t.Fatalf("seed %s = %+v, %v", key, res, err)
+		}
+	}
+	db := svc.DB
+	svc.DB = failMoveStore{db}
+
+	if _, err := svc.RemoveImage(ctx, "h", 1, "test"); err == nil {
+		t.Fatalf("RemoveImage succeeded, want the move error")
+	}
+	img, ok, err := db.GetGalleryImage(ctx, "h", 1)
+	if err != nil || !ok || img.SourceKey != "a" || img.Status != "active" || img.R2Key != "ri/h/1.webp" {
+		t.Fatalf("seq 1 = %+v, %v, %v; want a restored", img, ok, err)
+	}
+
+	// Once D1 is back the same takedown goes through.
+	svc.DB = db
+	if res, err := svc.RemoveImage(ctx, "h", 1, "test"); err != nil || res.Moved == nil || res.Moved.SourceKey != "b" {
+		t.Fatalf("retry = %+v, %v; want b moved into seq 1", res, err)
+	}
+}
+
+// fakeVariantProcessor renders each width as "<data>@<width>".
+type fakeVariantProcessor struct{ fakeProcessor }
+
+func (fakeVariantProcessor) PrepareVariants(_ context.Context, data []byte, widths []int) ([]PreparedVariant, error) {
+	var out []PreparedVariant
+	for _, w := range NormalizeVariantWidths(widths) {
+		b := []byte(fmt.Sprintf("%s@%d", data, w))
+		out = append(out, PreparedVariant{Width: w, Height: w / 2, WebPBytes: b, Bytes: int64(len(b))})
+	}
+	return out, nil
+}
+
+func TestVariantsFollowTheirSlot(t *testing.T) {
+	ctx := context.Background()
+	svc, fs := newTestService(t)
+	svc.Processor = fakeVariantProcessor{}
+	svc.VariantWidths = []int{480}
+
+	for _, key := range []string{"a", "b", "c"} {
+		if res, err := svc.StoreToGallery(ctx, StoreInput{Source: "tg", SourceKey: key, RawData: []byte("img-" + key)}); err != nil || !res.Added {
+			t.Fatalf("seed %s = %+v, %v", key, res, err)
+		}
+	}
+	if got, _, err := fs.GetObject(ctx, VariantKey("h", 2, 480)); err != nil || string(got) != "img-b@480" {
+		t.Fatalf("variant h/2 = %q, %v", got, err)
+	}
+
+	if _, err := svc.RemoveImage(ctx, "h", 1, "test"); err != nil {
+		t.Fatalf("RemoveImage: %v", err)
+	}
+	if got, _, err := fs.GetObject(ctx, VariantKey("h", 1, 480)); err != nil || string(got) != "img-c@480" {
+		t.Fatalf("variant h/1 after move = %q, %v; want img-c@480", got, err)
+	}
+	if _, _, err := fs.GetObject(ctx, VariantKey("h", 3, 480)); err == nil {
+		t.Fatalf("tail variant h/3 still exists")
+	}
+	if vs, err := svc.DB.ListGalleryVariants(ctx, "h", 3); err != nil || len(vs) != 0 {
+		t.Fatalf("variants of h/3 = %+v, %v; want none", vs, err)
+	}
+
+	// A newly configured width is filled in for every existing seq.
+	svc.VariantWidths = []int{480, 1080}
+	res, err := svc.BackfillVariants(ctx, "h", 0)
+	if err != nil {
+		t.Fatalf("BackfillVariants: %v", err)
+	}
+	if res.Scanned != 2 || res.Generated != 2 || res.Failed != 0 {
+		t.Fatalf("backfill = %+v, want 2 scanned and generated", res)
+	}
+	if got, _, err := fs.GetObject(ctx, VariantKey("h", 1, 1080)); err != nil || string(got) != "img-c@1080" {
+		t.Fatalf("backfilled h/1 = %q, %v", got, err)
+	}
+	if res, err := svc.BackfillVariants(ctx, "h", 0); err != nil || res.Generated != 0 {
+		t.Fatalf("second backfill = %+v, %v; want nothing to do", res, err)
+	}
+}
+
+// phashProcessor is fakeProcessor with the first 16 bytes of data used as
+// the hex phash.
+type phashProcessor struct{ fakeProcessor }
+
+func (p phashProcessor) Prepare(ctx context.Context, data []byte) (PreparedImage, error) {
+	prepared, err := p.fakeProcessor.Prepare(ctx, data)
+	prepared.PHash = string(data[:16])
+	return prepared, err
+}
+
+func TestStoreToGallerySkipsNearDuplicates(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newTestService(t)
+	svc.Processor = phashProcessor{}
+
+	store := func(key, data string) StoreResult {
+		t.Helper()
+		res, err := svc.StoreToGallery(ctx, StoreInput{Source: "tg", SourceKey: key, RawData: []byte(data)})
+		if err != nil {
+			t.Fatalf("store %s: %v", key, err)
+		}
+		return res
+	}
+	if res := store("a", "0000000000000000 a"); !res.Added {
+		t.Fatalf("a = %+v, want added", res)
+	}
+	if res := store("b", "0000000000000001 b"); res.SkipReason != "near_duplicate of h/1, distance 1" {
+		t.Fatalf("b skip = %q", res.SkipReason)
+	}
+	if res := store("c", "ffffffffffffffff c"); !res.Added {
+		t.Fatalf("c = %+v, want added", res)
+	}
+	// Rows added after the index was loaded are matched too.
+	if res := store("d", "fffffffffffffff0 d"); res.SkipReason != "near_duplicate of h/2, distance 4" {
+		t.Fatalf("d skip = %q", res.SkipReason)
+	}
+
+	if _, err := svc.RemoveImage(ctx, "h", 1, "test"); err != nil {
+		t.Fatalf("RemoveImage: %v", err)
+	}
+	if res := store("e", "0000000000000003 e"); res.SkipReason != "near_duplicate of removed a, distance 2" {
+		t.Fatalf("e skip = %q", res.SkipReason)
+	}
+}
@@ -0,0 +1,289 @@
+package gallery
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log/slog"
+	"sort"
+
+	"golang.org/x/image/draw"
+
+	"tyr-blog-img/internal/database"
+)
+
+// DefaultVariantWidths are the downscaled copies rendered next to every
+// full-size image when nothing else is configured.
+var DefaultVariantWidths = []int{480, 1080}
+
+// PreparedVariant is one rendered width. Width is the configured width used
+// in the key; Height is the actual pixel height, which for sources narrower
+// than Width is the source height.
+type PreparedVariant struct {
+	Width     int
+	Height    int
+	WebPBytes []byte
+	Bytes     int64
+}
+
+// VariantProcessor is implemented by processors that can render width
+// variants from an already stored WebP. Widths at or above the source width
+// reuse the source bytes instead of upscaling, so every configured width
+// exists for every seq. Downscaled variants of an animated WebP are stills
+// of its first frame.
+type VariantProcessor interface {
+	PrepareVariants(ctx context.Context, webpData []byte, widths []int) ([]PreparedVariant, error)
+}
+
+// VariantKey is the object key of the width variant of orientation/seq,
+// e.g. ri/h/w480/12.webp.
+func VariantKey(orientation string, seq int64, width int) string {
+	return fmt.Sprintf("ri/%s/w%d/%d.webp", orientation, width, seq)
+}
+
+// NormalizeVariantWidths sorts widths and drops duplicates and non-positive
+// values.
+func NormalizeVariantWidths(widths []int) []int {
+	out := make([]int, 0, len(widths))
+	seen := make(map[int]struct{}, len(widths))
+	for _, w := range widths {
+		if w <= 0 {
+			continue
+		}
+		if _, ok := seen[w]; ok {
+			continue
+		}
+		seen[w] = struct{}{}
+		out = append(out, w)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func (p *HybridWebPProcessor) PrepareVariants(ctx context.Context, webpData []byte, widths []int) ([]PreparedVariant, error) {
+	widths = NormalizeVariantWidths(widths)
+	if len(widths) == 0 {
+		return nil, nil
+	}
+	src, err := decodeStill(webpData)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	b := src.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return nil, fmt.Errorf("invalid image size")
+	}
+
+	out := make([]PreparedVariant, 0, len(widths))
+	for _, w := range widths {
+		if w >= b.Dx() {
+			out = append(out, PreparedVariant{
+				Width:     w,
+				Height:    b.Dy(),
+				WebPBytes: webpData,
+				Bytes:     int64(len(webpData)),
+			})
+			continue
+		}
+		h := (b.Dy()*w + b.Dx()/2) / b.Dx()
+		if h < 1 {
+			h = 1
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+		data, err := p.encodeWithCWebP(ctx, dst)
+		if err != nil {
+			return nil, fmt.Errorf("encode %dw variant: %w", w, err)
+		}
+		out = append(out, PreparedVariant{
+			Width:     w,
+			Height:    h,
+			WebPBytes: data,
+			Bytes:     int64(len(data)),
+		})
+	}
+	return out, nil
+}
+
+// prepareVariants renders the configured widths for a new image. Variants
+// are best effort: a failure is logged and the image is stored without
+// them, to be filled in later by BackfillVariants.
+func (s *Service) prepareVariants(ctx context.Context, prepared PreparedImage) []PreparedVariant {
+	if len(prepared.Variants) > 0 || len(s.VariantWidths) == 0 {
+		return prepared.Variants
+	}
+	vp, ok := s.Processor.(VariantProcessor)
+	if !ok {
+		return nil
+	}
+	variants, err := vp.PrepareVariants(ctx, prepared.WebPBytes, s.VariantWidths)
+	if err != nil {
+		slog.WarnContext(ctx, "gallery variants skipped", "sha256", prepared.SHA256, "err", err)
+		return nil
+	}
+	return variants
+}
+
+// storeVariants uploads variants for a slot the caller already owns and
+// records them in the DB.
+func (s *Service) storeVariants(ctx context.Context, orientation string, seq int64, variants []PreparedVariant) error {
+	for _, v := range variants {
+		key := VariantKey(orientation, seq, v.Width)
+		if err := s.Store.PutObjectWithCacheControl(ctx, key, v.WebPBytes, "image/webp", SlotCacheControl); err != nil {
+			return fmt.Errorf("upload r2 %s: %w", key, err)
+		}
+		err := s.DB.UpsertGalleryVariant(ctx, database.GalleryVariant{
+			Orientation: orientation,
+			Seq:         seq,
+			Width:       v.Width,
+			Height:      v.Height,
+			Bytes:       v.Bytes,
+		})
+		if err != nil {
+			return fmt.Errorf("record variant %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// deleteVariants drops every variant object and row of a slot.
+func (s *Service) deleteVariants(ctx context.Context, orientation string, seq int64) error {
+	variants, err := s.DB.ListGalleryVariants(ctx, orientation, seq)
+	if err != nil {
+		return err
+	}
+	for _, v := range variants {
+		if err := s.Store.DeleteObject(ctx, VariantKey(orientation, seq, v.Width)); err != nil {
+			return err
+		}
+	}
+	return s.DB.DeleteGalleryVariants(ctx, orientation, seq)
+}
+
+// moveVariants copies the variants of fromSeq over toSeq, removes any toSeq
+// variant the source does not have, and then drops the fromSeq objects.
+func (s *Service) moveVariants(ctx context.Context, orientation string, fromSeq, toSeq int64) error {
+	from, err := s.DB.ListGalleryVariants(ctx, orientation, fromSeq)
+	if err != nil {
+		return err
+	}
+	to, err := s.DB.ListGalleryVariants(ctx, orientation, toSeq)
+	if err != nil {
+		return err
+	}
+	have := make(map[int]struct{}, len(from))
+	for _, v := range from {
+		have[v.Width] = struct{}{}
+		if err := s.copySlotObject(ctx, VariantKey(orientation, fromSeq, v.Width), VariantKey(orientation, toSeq, v.Width)); err != nil {
+			return err
+		}
+	}
+	for _, v := range to {
+		if _, ok := have[v.Width]; ok {
+			continue
+		}
+		if err := s.Store.DeleteObject(ctx, VariantKey(orientation, toSeq, v.Width)); err != nil {
+			return err
+		}
+	}
+	if err := s.DB.MoveGalleryVariants(ctx, orientation, fromSeq, toSeq); err != nil {
+		return err
+	}
+	for _, v := range from {
+		if err := s.Store.DeleteObject(ctx, VariantKey(orientation, fromSeq, v.Width)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type VariantBackfillResult struct {
+	Scanned   int
+	Generated int
+	Failed    int
+	// LastError is the most recent per-seq failure, for the summary line.
+	LastError string
+}
+
+// BackfillVariants renders missing width variants for existing seqs of one
+// orientation, including legacy seqs that have an object but no D1 row. At
+// most limit seqs are processed per call (limit <= 0 means no limit), so
+// large galleries can be caught up over several runs.
+func (s *Service) BackfillVariants(ctx context.Context, orientation string, limit int) (VariantBackfillResult, error) {
+	var res VariantBackfillResult
+	if s == nil || s.DB == nil || s.Store == nil {
+		return res, fmt.Errorf("gallery service not fully configured")
+	}
+	if orientation != "h" && orientation != "v" {
+		return res, fmt.Errorf("invalid orientation %q", orientation)
+	}
+	widths := NormalizeVariantWidths(s.VariantWidths)
+	if len(widths) == 0 {
+		return res, fmt.Errorf("no variant widths configured")
+	}
+	vp, ok := s.Processor.(VariantProcessor)
+	if !ok {
+		return res, fmt.Errorf("processor cannot render variants")
+	}
+
+	next, err := s.DB.NextGallerySeq(ctx, orientation)
+	if err != nil {
+		return res, err
+	}
+	have := make(map[int]map[int64]struct{}, len(widths))
+	for _, w := range widths {
+		seqs, err := s.DB.ListGalleryVariantSeqs(ctx, orientation, w)
+		if err != nil {
+			return res, err
+		}
+		set := make(map[int64]struct{}, len(seqs))
+		for _, seq := range seqs {
+			set[seq] = struct{}{}
+		}
+		have[w] = set
+	}
+
+	for seq := int64(1); seq < next; seq++ {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if limit > 0 && res.Generated+res.Failed >= limit {
+			break
+		}
+		res.Scanned++
+		var missing []int
+		for _, w := range widths {
+			if _, ok := have[w][seq]; !ok {
+				missing = append(missing, w)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		if err := s.backfillSeqVariants(ctx, vp, orientation, seq, missing); err != nil {
+			res.Failed++
+			res.LastError = fmt.Sprintf("%s/%d: %v", orientation, seq, err)
+			slog.WarnContext(ctx, "gallery variant backfill failed", "orientation", orientation, "seq", seq, "err", err)
+			continue
+		}
+		res.Generated++
+	}
+	return res, nil
+}
+
+// backfillSeqVariants renders and stores the missing widths of one seq. The
+// slot is held so a takedown cannot move another image into it meanwhile.
+func (s *Service) backfillSeqVariants(ctx context.Context, vp VariantProcessor, orientation string, seq int64, widths []int) error {
+	s.slots.RLock()
+	defer s.slots.RUnlock()
+	data, _, err := s.Store.GetObject(ctx, fmt.Sprintf("ri/%s/%d.webp", orientation, seq))
+	if err != nil {
+		return err
+	}
+	variants, err := vp.PrepareVariants(ctx, data, widths)
+	if err != nil {
+		return err
+	}
+	return s.storeVariants(ctx, orientation, seq, variants)
+}
@@ -0,0 +1,95 @@
+// Package logging configures the process-wide slog logger and carries
+// per-ingest attributes through contexts.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Setup installs a text or JSON handler at level as the slog default. The
+// standard log package is routed through it as well. Unknown formats fall
+// back to text and unknown levels to info.
+func Setup(w io.Writer, format, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: ParseLevel(level)}
+	var h slog.Handler
+	if strings.EqualFold(strings.TrimSpace(format), "json") {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	logger := slog.New(contextHandler{h})
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ParseLevel maps debug, info, warn/warning and error to a slog level.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type attrsKey struct{}
+
+// With returns ctx carrying attrs; every record logged with that context
+// (slog.InfoContext and friends) gets them appended.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	merged = append(merged, prev...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// WithIngest tags ctx with ingest_id "source:key", the id to search for when
+// following one item from download to insert. The outermost caller wins: a
+// link ingested from a Telegram message keeps the id of the message.
+func WithIngest(ctx context.Context, source, key string) context.Context {
+	if IngestID(ctx) != "" {
+		return ctx
+	}
+	return With(ctx, slog.String("ingest_id", source+":"+key))
+}
+
+// IngestID returns the ingest_id carried by ctx, if any.
+func IngestID(ctx context.Context) string {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	for _, a := range attrs {
+		if a.Key == "ingest_id" {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+// contextHandler appends the attributes stored by With to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+			r.AddAttrs(attrs...)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
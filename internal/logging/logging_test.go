@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestIngestIDOnRecords(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var buf bytes.Buffer
+	logger := Setup(&buf, "json", "debug")
+
+	ctx := WithIngest(context.Background(), "tg", "100_7")
+	// Inner ingestors must not replace the id of the message.
+	ctx = WithIngest(ctx, "pixiv", "123")
+	ctx = With(ctx, slog.String("source_key", "pixiv_123_p0"))
+	logger.DebugContext(ctx, "gallery image prepared", "width", 1600)
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	if rec["ingest_id"] != "tg:100_7" || rec["source_key"] != "pixiv_123_p0" || rec["level"] != "DEBUG" {
+		t.Fatalf("record = %v", rec)
+	}
+}
+
+func TestSetupLevel(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var buf bytes.Buffer
+	logger := Setup(&buf, "text", "warn")
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("info logged at warn level: %q", buf.String())
+	}
+	logger.Warn("shown")
+	if !bytes.Contains(buf.Bytes(), []byte("msg=shown")) {
+		t.Fatalf("warn not logged: %q", buf.String())
+	}
+}
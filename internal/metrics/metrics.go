@@ -0,0 +1,139 @@
+// Package metrics holds the Prometheus collectors served on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tyr"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	storeResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gallery_store_total",
+		Help:      "StoreToGallery outcomes by source; result is added, error or the skip reason.",
+	}, []string{"source", "result"})
+
+	prepareSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gallery_prepare_seconds",
+		Help:      "Time spent decoding and transcoding one image to WebP.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	})
+
+	backendSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "backend_request_seconds",
+		Help:      "Latency of R2 and D1 calls.",
+		Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"backend", "op", "result"})
+
+	crawlerRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "crawler_runs_total",
+		Help:      "Finished crawler runs by result (success or failure).",
+	}, []string{"crawler", "result"})
+
+	crawlerRunSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "crawler_run_duration_seconds",
+		Help:      "Duration of one crawler run.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"crawler"})
+
+	crawlerLastRun = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "crawler_last_run_timestamp_seconds",
+		Help:      "Unix time the crawler last finished a run.",
+	}, []string{"crawler"})
+
+	crawlerLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "crawler_last_success_timestamp_seconds",
+		Help:      "Unix time the crawler last finished a successful run.",
+	}, []string{"crawler"})
+
+	galleryImages = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "gallery_images",
+		Help:      "Current image count (highest seq) per orientation.",
+	}, []string{"orientation"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		storeResults,
+		prepareSeconds,
+		backendSeconds,
+		crawlerRuns,
+		crawlerRunSeconds,
+		crawlerLastRun,
+		crawlerLastSuccess,
+		galleryImages,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveStore counts one StoreToGallery call. Skip reasons carrying
+// details ("near_duplicate of h/12, distance 3") are cut to their first
+// word to keep the label bounded.
+func ObserveStore(source string, added bool, skipReason string, err error) {
+	result := "added"
+	switch {
+	case err != nil:
+		result = "error"
+	case !added:
+		result, _, _ = strings.Cut(skipReason, " ")
+		if result == "" {
+			result = "skipped"
+		}
+	}
+	storeResults.WithLabelValues(source, result).Inc()
+}
+
+// ObservePrepare records the duration of one image Prepare call.
+func ObservePrepare(d time.Duration) {
+	prepareSeconds.Observe(d.Seconds())
+}
+
+// ObserveBackend records one R2 or D1 call started at start.
+func ObserveBackend(backend, op string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	backendSeconds.WithLabelValues(backend, op, result).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCrawl records a crawler run started at start.
+func ObserveCrawl(crawler string, start time.Time, ok bool) {
+	now := time.Now()
+	crawlerRunSeconds.WithLabelValues(crawler).Observe(now.Sub(start).Seconds())
+	crawlerLastRun.WithLabelValues(crawler).Set(float64(now.Unix()))
+	if ok {
+		crawlerRuns.WithLabelValues(crawler, "success").Inc()
+		crawlerLastSuccess.WithLabelValues(crawler).Set(float64(now.Unix()))
+	} else {
+		crawlerRuns.WithLabelValues(crawler, "failure").Inc()
+	}
+}
+
+// SetGalleryCounts updates the per-orientation image gauges.
+func SetGalleryCounts(h, v int64) {
+	galleryImages.WithLabelValues("h").Set(float64(h))
+	galleryImages.WithLabelValues("v").Set(float64(v))
+}
@@ -1,12 +1,14 @@
 package pixiv
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"time"
 )
 
@@ -83,6 +85,183 @@ func (c *Client) FetchBookmarkIDs(offset, limit int, tag string) ([]string, int,
 	return ids, data.Body.Total, nil
 }
 
+type profileAllResp struct {
+	Body struct {
+		// Both are {"<id>": null, ...} maps, or [] when the user has none.
+		Illusts json.RawMessage `json:"illusts"`
+		Manga   json.RawMessage `json:"manga"`
+	} `json:"body"`
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+}
+
+// FetchUserIllustIDs returns every illust and manga id of a user, newest
+// first.
+func (c *Client) FetchUserIllustIDs(userID string) ([]string, error) {
+	u := fmt.Sprintf("https://www.pixiv.net/ajax/user/%s/profile/all", url.PathEscape(userID))
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req, c.cookie)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var data profileAllResp
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Error {
+		return nil, fmt.Errorf("pixiv error: %s", data.Message)
+	}
+
+	var ids []string
+	for _, raw := range []json.RawMessage{data.Body.Illusts, data.Body.Manga} {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 || raw[0] != '{' {
+			continue
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+		for id := range m {
+			ids = append(ids, id)
+		}
+	}
+	SortIDsDesc(ids)
+	return ids, nil
+}
+
+type followLatestResp struct {
+	Body struct {
+		Page struct {
+			IDs []flexString `json:"ids"`
+		} `json:"page"`
+	} `json:"body"`
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+}
+
+// FetchFollowingIDs returns one page (1-based) of the logged-in user's
+// following feed, newest first.
+func (c *Client) FetchFollowingIDs(page int) ([]string, error) {
+	if page < 1 {
+		page = 1
+	}
+	q := url.Values{}
+	q.Set("p", fmt.Sprintf("%d", page))
+	q.Set("mode", "all")
+	q.Set("lang", "zh")
+	req, err := http.NewRequest(http.MethodGet, "https://www.pixiv.net/ajax/follow_latest/illust?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req, c.cookie)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var data followLatestResp
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Error {
+		return nil, fmt.Errorf("pixiv error: %s", data.Message)
+	}
+	ids := make([]string, 0, len(data.Body.Page.IDs))
+	for _, id := range data.Body.Page.IDs {
+		if id != "" {
+			ids = append(ids, string(id))
+		}
+	}
+	return ids, nil
+}
+
+// SortIDsDesc sorts numeric pixiv ids from newest to oldest.
+func SortIDsDesc(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) > len(ids[j])
+		}
+		return ids[i] > ids[j]
+	})
+}
+
+type RankingEntry struct {
+	IllustID  flexString `json:"illust_id"`
+	Title     string     `json:"title"`
+	Rank      int        `json:"rank"`
+	Width     int        `json:"width"`
+	Height    int        `json:"height"`
+	Tags      []string   `json:"tags"`
+	UserID    flexString `json:"user_id"`
+	PageCount flexString `json:"illust_page_count"`
+}
+
+func (e RankingEntry) ID() string { return string(e.IllustID) }
+
+type RankingResp struct {
+	Contents  []RankingEntry `json:"contents"`
+	Mode      string         `json:"mode"`
+	Content   string         `json:"content"`
+	Date      string         `json:"date"`
+	Page      int            `json:"page"`
+	RankTotal int            `json:"rank_total"`
+	Error     string         `json:"error"`
+}
+
+// FetchRanking returns one 50-entry page (1-based) of a ranking. An empty
+// date means the latest one; mode is daily, weekly, monthly, ... and
+// content is illust, manga or all ("").
+func (c *Client) FetchRanking(mode, content, date string, page int) (*RankingResp, error) {
+	if page < 1 {
+		page = 1
+	}
+	q := url.Values{}
+	q.Set("mode", mode)
+	if content != "" && content != "all" {
+		q.Set("content", content)
+	}
+	if date != "" {
+		q.Set("date", date)
+	}
+	q.Set("p", fmt.Sprintf("%d", page))
+	q.Set("format", "json")
+	req, err := http.NewRequest(http.MethodGet, "https://www.pixiv.net/ranking.php?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req, c.cookie)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var data RankingResp
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Error != "" {
+		return nil, fmt.Errorf("pixiv ranking error: %s", data.Error)
+	}
+	return &data, nil
+}
+
+// DetailResp.Body.XRestrict and AIType values.
+const (
+	XRestrictAllAges = 0
+	XRestrictR18     = 1
+	XRestrictR18G    = 2
+
+	AITypeUnknown   = 0
+	AITypeNotAI     = 1
+	AITypeGenerated = 2
+)
+
 type DetailResp struct {
 	Body struct {
 		IllustID    string `json:"illustId"`
@@ -91,6 +270,8 @@ type DetailResp struct {
 		UserID      string `json:"userId"`
 		UserName    string `json:"userName"`
 		IllustType  int    `json:"illustType"`
+		XRestrict   int    `json:"xRestrict"`
+		AIType      int    `json:"aiType"`
 		Tags        struct {
 			Tags []struct {
 				Tag string `json:"tag"`
@@ -123,6 +304,93 @@ func (c *Client) FetchDetail(id string) (*DetailResp, error) {
 	return &data, nil
 }
 
+// IllustTypeUgoira is DetailResp.Body.IllustType of animated works.
+const IllustTypeUgoira = 2
+
+type UgoiraFrame struct {
+	File  string `json:"file"`
+	Delay int    `json:"delay"` // milliseconds
+}
+
+type UgoiraMeta struct {
+	Src         string        `json:"src"`
+	OriginalSrc string        `json:"originalSrc"`
+	MimeType    string        `json:"mime_type"`
+	Frames      []UgoiraFrame `json:"frames"`
+}
+
+type ugoiraMetaResp struct {
+	Body    UgoiraMeta `json:"body"`
+	Error   bool       `json:"error"`
+	Message string     `json:"message"`
+}
+
+// FetchUgoiraMeta returns the frame zip URLs and per-frame delays of an
+// ugoira work.
+func (c *Client) FetchUgoiraMeta(id string) (*UgoiraMeta, error) {
+	u := fmt.Sprintf("https://www.pixiv.net/ajax/illust/%s/ugoira_meta?lang=zh", id)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req, c.cookie)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var data ugoiraMetaResp
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Error {
+		return nil, fmt.Errorf("pixiv error: %s", data.Message)
+	}
+	if len(data.Body.Frames) == 0 {
+		return nil, fmt.Errorf("pixiv ugoira has no frames")
+	}
+	return &data.Body, nil
+}
+
+// ZipURL prefers the original-size frame zip.
+func (m *UgoiraMeta) ZipURL() string {
+	if m.OriginalSrc != "" {
+		return m.OriginalSrc
+	}
+	return m.Src
+}
+
+// ExtractUgoiraFrames returns the frame files of a downloaded ugoira zip in
+// the order of meta.Frames.
+func ExtractUgoiraFrames(zipData []byte, meta *UgoiraMeta) ([][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, fmt.Errorf("open ugoira zip: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	out := make([][]byte, 0, len(meta.Frames))
+	for _, fr := range meta.Frames {
+		f, ok := files[fr.File]
+		if !ok {
+			return nil, fmt.Errorf("ugoira frame %s missing from zip", fr.File)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open ugoira frame %s: %w", fr.File, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read ugoira frame %s: %w", fr.File, err)
+		}
+		out = append(out, data)
+	}
+	return out, nil
+}
+
 type pageResp struct {
 	Body []struct {
 		Urls struct {
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fsMetaDir holds the sidecar metadata files. It is a dot directory so that a
+// static file server in front of the root can hide it easily.
+const fsMetaDir = ".meta"
+
+type FSConfig struct {
+	Root string
+}
+
+// FSClient is a local filesystem object store with the same key layout as
+// R2 (ri/h/{seq}.webp, counts.json, random.js, ...). Content type and cache
+// control are kept in JSON sidecars under {root}/.meta/.
+type FSClient struct {
+	root string
+}
+
+type fsObjectMeta struct {
+	ContentType  string `json:"content_type"`
+	CacheControl string `json:"cache_control"`
+}
+
+func NewFSClient(cfg FSConfig) (*FSClient, error) {
+	root := strings.TrimSpace(cfg.Root)
+	if root == "" {
+		return nil, fmt.Errorf("fs storage root is empty")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("create fs storage root: %w", err)
+	}
+	return &FSClient{root: abs}, nil
+}
+
+func (c *FSClient) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	return c.PutObjectWithCacheControl(ctx, key, data, contentType, "public, max-age=31536000, immutable")
+}
+
+func (c *FSClient) PutObjectWithCacheControl(ctx context.Context, key string, data []byte, contentType, cacheControl string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	objPath, metaPath, err := c.paths(key)
+	if err != nil {
+		return err
+	}
+	contentType = strings.TrimSpace(contentType)
+	cacheControl = strings.TrimSpace(cacheControl)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if cacheControl == "" {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+
+	meta, err := json.Marshal(fsObjectMeta{ContentType: contentType, CacheControl: cacheControl})
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(objPath, data); err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, meta)
+}
+
+// PutObjectIfAbsent never replaces an existing object: the payload is
+// hard-linked into place, which fails atomically when the key is taken.
+func (c *FSClient) PutObjectIfAbsent(ctx context.Context, key string, data []byte, contentType, cacheControl string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	objPath, metaPath, err := c.paths(key)
+	if err != nil {
+		return false, err
+	}
+	contentType = strings.TrimSpace(contentType)
+	cacheControl = strings.TrimSpace(cacheControl)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if cacheControl == "" {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+	meta, err := json.Marshal(fsObjectMeta{ContentType: contentType, CacheControl: cacheControl})
+	if err != nil {
+		return false, err
+	}
+
+	tmpName, err := writeTempFile(filepath.Dir(objPath), data)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpName)
+	if err := os.Link(tmpName, objPath); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	// Without its meta the object would be served with a guessed type, so
+	// undo the link and let the caller treat the key as still free.
+	if err := writeFileAtomic(metaPath, meta); err != nil {
+		os.Remove(objPath)
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *FSClient) GetObject(ctx context.Context, key string) ([]byte, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	objPath, metaPath, err := c.paths(key)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(objPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType := ""
+	if raw, err := os.ReadFile(metaPath); err == nil {
+		var meta fsObjectMeta
+		if json.Unmarshal(raw, &meta) == nil {
+			contentType = strings.TrimSpace(meta.ContentType)
+		}
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(objPath))
+	}
+	return data, contentType, nil
+}
+
+// ObjectETag mirrors the S3 ETag of a single-part upload: the quoted MD5
+// of the content.
+func (c *FSClient) ObjectETag(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	objPath, _, err := c.paths(key)
+	if err != nil {
+		return "", false, err
+	}
+	data, err := os.ReadFile(objPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`, true, nil
+}
+
+func (c *FSClient) DeleteObject(ctx context.Context, key string) error {
+	if strings.TrimSpace(key) == "" {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	objPath, metaPath, err := c.paths(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(objPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(metaPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (c *FSClient) paths(key string) (objPath, metaPath string, err error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", "", fmt.Errorf("empty key")
+	}
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.HasSuffix(key, "/") {
+		return "", "", fmt.Errorf("invalid key %q", key)
+	}
+	clean = strings.TrimPrefix(clean, "/")
+	if clean == fsMetaDir || strings.HasPrefix(clean, fsMetaDir+"/") {
+		return "", "", fmt.Errorf("invalid key %q", key)
+	}
+	objPath = filepath.Join(c.root, filepath.FromSlash(clean))
+	metaPath = filepath.Join(c.root, fsMetaDir, filepath.FromSlash(clean)+".json")
+	return objPath, metaPath, nil
+}
+
+func writeFileAtomic(target string, data []byte) error {
+	tmpName, err := writeTempFile(filepath.Dir(target), data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, target); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+func writeTempFile(dir string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return "", err
+	}
+	if err := os.Chmod(tmpName, 0o644); err != nil {
+		_ = os.Remove(tmpName)
+		return "", err
+	}
+	return tmpName, nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFSClientRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	c, err := NewFSClient(FSConfig{Root: root})
+	if err != nil {
+		t.Fatalf("NewFSClient: %v", err)
+	}
+
+	if err := c.PutObjectWithCacheControl(ctx, "counts.json", []byte(`{"h":1,"v":0}`), "application/json; charset=utf-8", "public, max-age=30"); err != nil {
+		t.Fatalf("PutObjectWithCacheControl: %v", err)
+	}
+	if err := c.PutObject(ctx, "ri/h/1.webp", []byte("RIFF"), "image/webp"); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "ri", "h", "1.webp")); err != nil {
+		t.Fatalf("object not written under root: %v", err)
+	}
+
+	data, contentType, err := c.GetObject(ctx, "counts.json")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	if string(data) != `{"h":1,"v":0}` || contentType != "application/json; charset=utf-8" {
+		t.Fatalf("GetObject = %q, %q", data, contentType)
+	}
+
+	if etag, ok, err := c.ObjectETag(ctx, "ri/h/1.webp"); err != nil || !ok || etag != `"202125ffec234c941c5e136c76367b95"` {
+		t.Fatalf("ObjectETag = %q, %v, %v; want md5 of RIFF", etag, ok, err)
+	}
+
+	if err := c.DeleteObject(ctx, "ri/h/1.webp"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, _, err := c.GetObject(ctx, "ri/h/1.webp"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("GetObject after delete err = %v, want not exist", err)
+	}
+	if _, ok, err := c.ObjectETag(ctx, "ri/h/1.webp"); err != nil || ok {
+		t.Fatalf("ObjectETag after delete = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestFSClientRejectsEscapingKeys(t *testing.T) {
+	c, err := NewFSClient(FSConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFSClient: %v", err)
+	}
+	for _, key := range []string{"", "/", ".meta/counts.json.json", "ri/h/"} {
+		if _, _, err := c.paths(key); err == nil {
+			t.Fatalf("paths(%q) succeeded, want error", key)
+		}
+	}
+	objPath, _, err := c.paths("../../etc/passwd")
+	if err != nil {
+		t.Fatalf("paths: %v", err)
+	}
+	if rel, err := filepath.Rel(c.root, objPath); err != nil || rel != filepath.Join("etc", "passwd") {
+		t.Fatalf("paths(../../etc/passwd) = %s, want it clamped under root", objPath)
+	}
+}
+
+func TestFSClientPutIfAbsentUndoesObjectOnMetaFailure(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewFSClient(FSConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFSClient: %v", err)
+	}
+	objPath, metaPath, err := c.paths("ri/h/1.webp")
+	if err != nil {
+		t.Fatalf("paths: %v", err)
+	}
+	// A directory where the meta file goes makes the meta rename fail.
+	if err := os.MkdirAll(metaPath, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	created, err := c.PutObjectIfAbsent(ctx, "ri/h/1.webp", []byte("RIFF"), "image/webp", "")
+	if err == nil || created {
+		t.Fatalf("PutObjectIfAbsent = %v, %v; want false and an error", created, err)
+	}
+	if _, err := os.Stat(objPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("object left behind after meta failure: %v", err)
+	}
+}
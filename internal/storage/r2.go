@@ -3,13 +3,18 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"time"
+
+	"tyr-blog-img/internal/metrics"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
 )
 
 type R2Config struct {
@@ -63,6 +68,27 @@ func (c *R2Client) PutObject(ctx context.Context, key string, data []byte, conte
 }
 
 func (c *R2Client) PutObjectWithCacheControl(ctx context.Context, key string, data []byte, contentType, cacheControl string) error {
+	return c.putObject(ctx, key, data, contentType, cacheControl, false)
+}
+
+// PutObjectIfAbsent uploads with If-None-Match: * so an existing object is
+// never overwritten. It reports false when the key is already taken.
+func (c *R2Client) PutObjectIfAbsent(ctx context.Context, key string, data []byte, contentType, cacheControl string) (bool, error) {
+	err := c.putObject(ctx, key, data, contentType, cacheControl, true)
+	if err == nil {
+		return true, nil
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return false, nil
+		}
+	}
+	return false, err
+}
+
+func (c *R2Client) putObject(ctx context.Context, key string, data []byte, contentType, cacheControl string, ifAbsent bool) error {
 	key = strings.TrimSpace(key)
 	contentType = strings.TrimSpace(contentType)
 	cacheControl = strings.TrimSpace(cacheControl)
@@ -76,13 +102,19 @@ func (c *R2Client) PutObjectWithCacheControl(ctx context.Context, key string, da
 		cacheControl = "public, max-age=31536000, immutable"
 	}
 
-	_, err := c.s3.PutObject(ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket:       &c.bucket,
 		Key:          &key,
 		Body:         bytes.NewReader(data),
 		ContentType:  &contentType,
 		CacheControl: &cacheControl,
-	})
+	}
+	if ifAbsent {
+		input.IfNoneMatch = strPtr("*")
+	}
+	start := time.Now()
+	_, err := c.s3.PutObject(ctx, input)
+	metrics.ObserveBackend("r2", "put", start, err)
 	return err
 }
 
@@ -91,10 +123,12 @@ func (c *R2Client) GetObject(ctx context.Context, key string) ([]byte, string, e
 	if key == "" {
 		return nil, "", fmt.Errorf("empty key")
 	}
+	start := time.Now()
 	out, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: &c.bucket,
 		Key:    &key,
 	})
+	metrics.ObserveBackend("r2", "get", start, err)
 	if err != nil {
 		return nil, "", err
 	}
@@ -111,15 +145,48 @@ func (c *R2Client) GetObject(ctx context.Context, key string) ([]byte, string, e
 	return data, contentType, nil
 }
 
+// ObjectETag returns the ETag of key, quotes included as S3 sends them.
+// For single-part uploads it is the MD5 of the content.
+func (c *R2Client) ObjectETag(ctx context.Context, key string) (string, bool, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", false, fmt.Errorf("empty key")
+	}
+	start := time.Now()
+	out, err := c.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &c.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) {
+			switch apiErr.ErrorCode() {
+			case "NotFound", "NoSuchKey":
+				metrics.ObserveBackend("r2", "head", start, nil)
+				return "", false, nil
+			}
+		}
+		metrics.ObserveBackend("r2", "head", start, err)
+		return "", false, err
+	}
+	metrics.ObserveBackend("r2", "head", start, nil)
+	if out.ETag == nil {
+		return "", true, nil
+	}
+	return strings.TrimSpace(*out.ETag), true, nil
+}
+
 func (c *R2Client) DeleteObject(ctx context.Context, key string) error {
 	key = strings.TrimSpace(key)
 	if key == "" {
 		return nil
 	}
+	start := time.Now()
 	_, err := c.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: &c.bucket,
 		Key:    &key,
 	})
+	metrics.ObserveBackend("r2", "delete", start, err)
 	return err
 }
 
@@ -94,6 +94,18 @@ func (c *Client) DownloadFile(ctx context.Context, fileID string) ([]byte, strin
 	return nil, "", lastErr
 }
 
+func (c *Client) SendText(ctx context.Context, chatID int64, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	_, err := c.Bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   text,
+	})
+	return err
+}
+
 func (c *Client) Start(ctx context.Context) {
 	c.Bot.Start(ctx)
 }